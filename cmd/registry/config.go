@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/willroberts/openrvs-registry/registry"
+)
+
+// pathFlags are the flag names and descriptions of settings whose flags predate
+// the config file. Every other setting has a flag named after it, with dashes
+// instead of underscores.
+var pathFlags = map[string][2]string{
+	"seed_path":       {"seed-file", "path to seed.csv"},
+	"checkpoint_path": {"checkpoint-file", "path to checkpoint.csv"},
+	"journal_path":    {"journal-file", "path to the journal of changes since the last checkpoint (default: checkpoint file path + .journal)"},
+	"graveyard_path":  {"graveyard-file", "path to archive removed expired servers to (default: no archive)"},
+	"ban_list_path":   {"ban-file", "path to the list of banned servers (default: checkpoint file path + .bans.json)"},
+	"audit_log_path":  {"audit-file", "path to record every change to the server list to (default: keep recent changes in memory)"},
+	"history_path":    {"history-file", "path to save healthcheck history to at each checkpoint (default: checkpoint file path + .history.json)"},
+}
+
+// setting is a single value given on the command line.
+type setting struct {
+	name  string
+	value string
+}
+
+// settingFlag is a flag.Value which records each use of the flag for a setting,
+// so that flags can be applied after the config file and environment.
+type settingFlag struct {
+	name     string
+	settings *[]setting
+}
+
+func (f settingFlag) String() string { return "" }
+
+func (f settingFlag) Set(value string) error {
+	// Parse the value now, so invalid flags are reported by the flag package.
+	var c registry.Config
+	if err := c.Set(f.name, value); err != nil {
+		return errors.Unwrap(err)
+	}
+	*f.settings = append(*f.settings, setting{f.name, value})
+	return nil
+}
+
+// registerSettingFlags defines a flag for every setting, recording the ones
+// which are used in settings.
+func registerSettingFlags(fs *flag.FlagSet, settings *[]setting) {
+	for _, name := range registry.Settings() {
+		flagName, usage := strings.ReplaceAll(name, "_", "-"), "sets "+name
+		if f, ok := pathFlags[name]; ok {
+			flagName, usage = f[0], f[1]
+		}
+		fs.Var(settingFlag{name, settings}, flagName, usage)
+	}
+}
+
+// defaultConfig returns the configuration used for any setting not given in the
+// config file, environment or flags.
+func defaultConfig() registry.Config {
+	return registry.Config{
+		CheckpointInterval:           5 * time.Minute,
+		CheckpointRotations:          3,
+		HealthcheckInterval:          30 * time.Second,
+		HealthcheckUnhealthyInterval: 2 * time.Minute,
+		HealthcheckExpiredInterval:   30 * time.Minute,
+		HealthcheckMaxInterval:       24 * time.Hour,
+		HealthcheckTimeout:           5 * time.Second,
+		HealthcheckHealthyThreshold:  1,
+		HealthcheckUnhealthyAfter:    30 * time.Minute,
+		HealthcheckExpiredAfter:      48 * time.Hour,
+		HealthcheckConcurrency:       64,
+		ExpiredRetention:             7 * 24 * time.Hour,
+		HTTPListenAddr:               "127.0.0.1:8080",
+		UDPListenAddr:                ":8080",
+		RateLimitInterval:            time.Minute,
+		RateLimitSourceBurst:         10,
+		RateLimitServerBurst:         3,
+		RegistrationDedupWindow:      time.Minute,
+		RegistrationVerification:     registry.VerifyProbe,
+		UDPWorkers:                   8,
+		UDPQueueSize:                 256,
+		ShutdownTimeout:              30 * time.Second,
+	}
+}
+
+// loadConfig builds the registry configuration. Each source overrides the ones
+// before it: defaults, then the config file at path (if any), then OPENRVS_*
+// environment variables, then flags. The result is validated.
+func loadConfig(path string, flags []setting) (registry.Config, error) {
+	config := defaultConfig()
+	if path != "" {
+		if err := config.LoadFile(path); err != nil {
+			return config, err
+		}
+	}
+	if err := config.LoadEnv(os.LookupEnv); err != nil {
+		return config, err
+	}
+	for _, s := range flags {
+		if err := config.Set(s.name, s.value); err != nil {
+			return config, err
+		}
+	}
+
+	// Files kept alongside the checkpoint default to paths derived from it.
+	if config.CheckpointPath != "" {
+		for _, p := range []struct {
+			path   *string
+			suffix string
+		}{
+			{&config.JournalPath, ".journal"},
+			{&config.BanListPath, ".bans.json"},
+			{&config.HistoryPath, ".history.json"},
+		} {
+			if *p.path == "" {
+				*p.path = config.CheckpointPath + p.suffix
+			}
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return config, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return config, nil
+}
@@ -1,107 +1,141 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
-	"log"
-	"net"
-	"time"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/willroberts/openrvs-registry/registry"
 )
 
 var (
-	seedPath       string
-	checkpointPath string
+	configPath string
+	storeDir   string
+	logLevel   string
+	logFormat  string
+	settings   []setting
 )
 
 func init() {
-	flag.StringVar(&seedPath, "seed-file", "", "path to seed.csv")
-	flag.StringVar(&checkpointPath, "checkpoint-file", "", "path to checkpoint.csv")
+	flag.StringVar(&configPath, "config-file", os.Getenv(registry.EnvPrefix+"CONFIG_FILE"), "path to a JSON config file (default: $OPENRVS_CONFIG_FILE)")
+	flag.StringVar(&storeDir, "store-dir", "", "directory for storing servers as they change, instead of checkpoint.csv")
+	flag.StringVar(&logLevel, "log-level", "info", "minimum level of log messages: debug, info, warn or error")
+	flag.StringVar(&logFormat, "log-format", "text", "format of log messages: text or json")
+	registerSettingFlags(flag.CommandLine, &settings)
 	flag.Parse()
 }
 
 func main() {
-	log.Println("openrvs-registry process started")
-
-	config := registry.Config{
-		SeedPath:                      seedPath,
-		CheckpointPath:                checkpointPath,
-		CheckpointInterval:            5 * time.Minute,
-		HealthcheckInterval:           30 * time.Second,
-		HealthcheckTimeout:            5 * time.Second,
-		HealthcheckHealthyThreshold:   1,
-		HealthcheckUnhealthyThreshold: 60,   // 30 minutes.
-		HealthcheckHiddenThreshold:    5760, // 2 days.
-		ListenAddr:                    "127.0.0.1:8080",
+	logger, err := newLogger(logLevel, logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+	slog.Info("openrvs-registry process started")
+
+	config, err := loadConfig(configPath, settings)
+	if err != nil {
+		slog.Error("failed to load configuration", "error", err)
+		os.Exit(2)
+	}
+	config.Logger = logger
+	config.LoadConfig = func() (registry.Config, error) {
+		return loadConfig(configPath, settings)
+	}
+	if storeDir != "" {
+		config.Store = registry.NewDirStore(storeDir)
 	}
 
 	reg := registry.NewRegistry(config)
 
-	// Attempt to load servers from checkpoint.csv, falling back to seed.csv.
-	log.Println("loading servers from file")
-	if err := reg.LoadServers(config.CheckpointPath); err != nil {
-		log.Println("unable to read checkpoint.csv, falling back to seed.csv")
-		log.Println(err)
-		if err := reg.LoadServers(config.SeedPath); err != nil {
-			log.Println("unable to read seed.csv, falling back to empty server list")
-			log.Println(err)
+	// Attempt to load servers from the store, falling back to seed.csv.
+	slog.Info("loading servers from file")
+	if err := logSkippedLines(reg.Restore(), "stored servers"); err != nil || reg.ServerCount() == 0 {
+		slog.Warn("unable to read stored servers, falling back to seed.csv", "error", err)
+		if err := logSkippedLines(reg.LoadServers(config.SeedPath), config.SeedPath); err != nil {
+			slog.Warn("unable to read seed.csv, falling back to empty server list", "error", err)
 		}
 	}
 
+	// Recover any changes made after the last checkpoint was saved.
+	n, err := reg.ReplayJournal()
+	if err := logSkippedLines(err, config.JournalPath); err != nil {
+		slog.Error("failed to replay journal", "error", err)
+	}
+	slog.Info("replayed journal", "changes", n)
+
+	// Restore healthcheck history for uptime statistics.
+	if err := reg.LoadHistory(); err != nil {
+		slog.Error("failed to load healthcheck history", "error", err)
+	}
+
+	// Remove any banned servers which were loaded.
+	if err := reg.LoadBans(); err != nil {
+		slog.Error("failed to load ban list", "error", err)
+	}
+
 	// Log the number of servers loaded from file.
-	log.Printf("there are now %d registered servers", reg.ServerCount())
+	slog.Info("loaded servers", "servers", reg.ServerCount())
+
+	// Run the registry until SIGINT or SIGTERM is received. Checkpoints are
+	// saved regularly while running, and once more before exiting. The
+	// checkpoint file can be backed up at an OS level if desired.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Regularly checkpoint servers to disk in a new thread. This file can be
-	// backed up at an OS level at regular intervals if desired.
-	// The go keyword launches a goroutine, which happens concurrently and does
-	// not block the current thread. For this reason, synchronization (such as
-	// with lock.Lock() below) is needed.
+	// Reload the config file and seed file on SIGHUP.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
 	go func() {
-		for {
-			time.Sleep(config.CheckpointInterval)
-			log.Println("saving checkpoint file to ", config.CheckpointPath)
-			if err := reg.SaveServers(config.CheckpointPath); err != nil {
-				log.Println("failed to write checkpoint file:", err)
+		for range hup {
+			if err := logSkippedLines(reg.Reload(), "seed file"); err != nil {
+				slog.Error("failed to reload", "error", err)
 			}
 		}
 	}()
+	if err := reg.Run(ctx); err != nil {
+		slog.Error("registry stopped unexpectedly", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("openrvs-registry process stopped")
+}
 
-	// Start listening on UDP/8080 for beacons in a new thread.
-	udpHandler := func(addr *net.UDPAddr, data []byte, err error) {
-		log.Println("received UDP from", addr.IP.String())
-		if err != nil {
-			log.Println("udp error:", err)
-			return
-		}
-		if err := reg.AddServer(addr.IP.String(), data); err != nil {
-			log.Println("registration error:", err)
-			return
-		}
-		log.Printf("there are now %d registered servers", reg.ServerCount())
+// newLogger returns a logger which writes messages of at least the given level
+// to stderr in the given format.
+func newLogger(level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
 	}
-	stopCh := make(chan struct{})
-	log.Println("listening on udp://0.0.0.0:8080")
-	go reg.HandleUDP(8080, udpHandler, stopCh)
 
-	// Start sending healthchecks in a new thread at the configured interval.
-	go func() {
-		log.Printf("sending healthchecks every %d seconds", config.HealthcheckInterval/time.Second)
-		for {
-			reg.SendHealthchecks(
-				// onHealthy
-				func(s registry.GameServer) {
-					log.Println("server is now healthy:", s.IP, s.Port)
-				},
-				// onUnhealthy
-				func(s registry.GameServer) {
-					log.Println("server is now unhealthy:", s.IP, s.Port)
-				},
-			)
-			time.Sleep(config.HealthcheckInterval)
-		}
-	}()
+	opts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+}
 
-	// Start listening for HTTP requests from OpenRVS clients.
-	log.Printf("listening on http://%s", config.ListenAddr)
-	log.Fatal(reg.HandleHTTP(config.ListenAddr))
+// logSkippedLines logs any malformed lines which were skipped while loading
+// servers from the given source. An error is only returned when no servers
+// could be loaded.
+func logSkippedLines(err error, source string) error {
+	var skipped registry.LineErrors
+	if errors.As(err, &skipped) {
+		slog.Warn("skipped invalid lines", "source", source, "count", len(skipped))
+		for _, lineErr := range skipped {
+			slog.Warn("invalid line", "source", source, "line", lineErr.Line, "error", lineErr.Err)
+		}
+		return nil
+	}
+	return err
 }
@@ -16,27 +16,40 @@ type githubResponse struct {
 }
 
 // GetLatestReleaseVersion retrieves the latest OpenRVS release tag from Github
-// and returns it as []byte.
+// and returns it as []byte. Errors are returned in the form "error: <message>".
 func GetLatestReleaseVersion() []byte {
+	version, err := LatestReleaseVersion()
+	if err != nil {
+		return []byte(fmt.Sprintf("error: %s", err.Error()))
+	}
+	return []byte(version)
+}
+
+// LatestReleaseVersion retrieves the latest OpenRVS release tag from Github.
+func LatestReleaseVersion() (string, error) {
 	// Get the HTTP response.
 	resp, err := http.Get(latestReleaseURL)
 	if err != nil {
-		return []byte(fmt.Sprintf("error: %s", err.Error()))
+		return "", err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected response from Github: %s", resp.Status)
+	}
+
 	// Read the response body as bytes.
 	bytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return []byte(fmt.Sprintf("error: %s", err.Error()))
+		return "", err
 	}
 
 	// Parse the JSON, storing it directly in githubResponse r.
 	var r githubResponse
 	if err := json.Unmarshal(bytes, &r); err != nil {
-		return []byte(fmt.Sprintf("error: %s", err.Error()))
+		return "", err
 	}
 
-	// Return just the latest version tag as []byte.
-	return []byte(r.TagName)
+	// Return just the latest version tag.
+	return r.TagName, nil
 }
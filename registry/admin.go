@@ -0,0 +1,277 @@
+package registry
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// serverPatch contains the fields of a GameServer which can be edited by
+// admins. Fields which are omitted are not changed.
+type serverPatch struct {
+	Name     *string `json:"name"`
+	GameMode *string `json:"mode"`
+	Hidden   *bool   `json:"hidden"`
+	Pinned   *bool   `json:"pinned"`
+}
+
+// apply updates the given server with the fields set in the patch.
+func (p serverPatch) apply(s *GameServer) {
+	if p.Name != nil {
+		s.Name = *p.Name
+	}
+	if p.GameMode != nil {
+		s.GameMode = *p.GameMode
+	}
+	if p.Hidden != nil {
+		s.Hidden = *p.Hidden
+	}
+	if p.Pinned != nil {
+		s.Pinned = *p.Pinned
+	}
+}
+
+// addAdminRoutes adds the admin endpoints to the given mux. Every admin
+// endpoint requires the bearer token in Config.AdminToken.
+func (r *registry) addAdminRoutes(mux *http.ServeMux) {
+	// Servers are identified by their ID, as in /admin/servers/1.2.3.4:6777.
+	mux.HandleFunc("/admin/servers/", r.requireAdmin(func(w http.ResponseWriter, req *http.Request) {
+		id := strings.TrimPrefix(req.URL.Path, "/admin/servers/")
+		o := r.adminOrigin(req)
+		if id, ok := strings.CutSuffix(id, "/healthcheck"); ok {
+			if req.Method != http.MethodPost {
+				w.Header().Set("Allow", "POST")
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				w.Write([]byte("request method must be POST"))
+				return
+			}
+			server, ok := r.healthcheckServer(id, o, func(GameServer) {}, func(GameServer) {})
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte("server not found"))
+				return
+			}
+			writeJSONValue(w, http.StatusOK, jsonServer{ID: id, GameServer: server})
+			return
+		}
+
+		switch req.Method {
+		case http.MethodGet:
+			r.GameServerMapLock.RLock()
+			server, ok := r.GameServerMap[id]
+			r.GameServerMapLock.RUnlock()
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte("server not found"))
+				return
+			}
+			writeJSONValue(w, http.StatusOK, jsonServer{ID: id, GameServer: server})
+
+		case http.MethodPatch:
+			var patch serverPatch
+			decoder := json.NewDecoder(req.Body)
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&patch); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("request body must contain a JSON object with name, mode, hidden or pinned"))
+				return
+			}
+			server, ok, err := r.editServer(id, patch, o)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte("server not found"))
+				return
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("server was edited but the change could not be recorded: " + err.Error()))
+				return
+			}
+			writeJSONValue(w, http.StatusOK, jsonServer{ID: id, GameServer: server})
+
+		case http.MethodDelete:
+			ok, err := r.removeServer(id, o)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte("server not found"))
+				return
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("server was removed but the change could not be recorded: " + err.Error()))
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.Header().Set("Allow", "GET, PATCH, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("request method must be GET, PATCH or DELETE"))
+		}
+	}))
+
+	mux.HandleFunc("/admin/audit", r.requireAdmin(r.auditQuery))
+	mux.HandleFunc("/admin/reload", r.requireAdmin(r.reloadHandler))
+
+	mux.HandleFunc("/admin/checkpoint", r.requireAdmin(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("request method must be POST"))
+			return
+		}
+		if err := r.Checkpoint(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("failed to save checkpoint: " + err.Error()))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	mux.HandleFunc("/admin/seed", r.requireAdmin(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("request method must be POST"))
+			return
+		}
+		added, err := r.MergeServers(r.config().SeedPath)
+		var skipped LineErrors
+		if err != nil && !errors.As(err, &skipped) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("failed to load seed file: " + err.Error()))
+			return
+		}
+		writeJSONValue(w, http.StatusOK, map[string]int{"added": added, "skipped": len(skipped)})
+	}))
+
+	mux.HandleFunc("/admin/bans", r.requireAdmin(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+			writeJSONValue(w, http.StatusOK, r.bans.List())
+
+		case http.MethodPost:
+			var ban Ban
+			if err := json.NewDecoder(req.Body).Decode(&ban); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("request body must contain a JSON ban"))
+				return
+			}
+			ban, err := r.bans.Add(ban)
+			if errors.Is(err, errInvalidBan) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("ban was added but could not be saved: " + err.Error()))
+				return
+			}
+			r.removeBannedServers(r.adminOrigin(req))
+			writeJSONValue(w, http.StatusCreated, ban)
+
+		case http.MethodDelete:
+			query := req.URL.Query()
+			removed, err := r.bans.Remove(BanKind(query.Get("kind")), query.Get("value"))
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("ban was removed but the change could not be saved: " + err.Error()))
+				return
+			}
+			if !removed {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte("ban not found"))
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("request method must be GET, POST or DELETE"))
+		}
+	}))
+}
+
+// requireAdmin wraps an admin endpoint, which responds with 401 Unauthorized
+// unless the request contains the bearer token in Config.AdminToken. Admin
+// endpoints are disabled when AdminToken is empty.
+func (r *registry) requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if r.config().AdminToken == "" {
+			http.NotFound(w, req)
+			return
+		}
+
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(r.config().AdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("a valid admin token is required"))
+			return
+		}
+
+		h(w, req)
+	}
+}
+
+// adminOrigin returns the origin of changes requested by an admin request.
+func (r *registry) adminOrigin(req *http.Request) origin {
+	return origin{channel: ChannelAdmin, sourceIP: clientIP(req, r.config().TrustProxyHeaders)}
+}
+
+// writeJSONValue writes the given value as a JSON response with the given
+// status code.
+func writeJSONValue(w http.ResponseWriter, status int, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("failed to serialize response"))
+		return
+	}
+	w.Header().Set("Content-Type", jsonContentType)
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// editServer applies the given patch to a server, and returns the updated
+// server. False is returned if the server is not known.
+func (r *registry) editServer(id string, patch serverPatch, o origin) (GameServer, bool, error) {
+	var e Event
+	r.GameServerMapLock.Lock()
+	before, ok := r.GameServerMap[id]
+	server := before
+	if ok {
+		patch.apply(&server)
+		r.GameServerMap[id] = server
+		e = r.sequence(newEvent(EventServerEdited, id, &before, server, o))
+	}
+	r.GameServerMapLock.Unlock()
+
+	if !ok {
+		return GameServer{}, false, nil
+	}
+	return server, true, r.emit(e)
+}
+
+// removeServer removes a server from the server list. False is returned if the
+// server is not known. Servers which are still running may register again; use
+// a Ban to prevent this.
+func (r *registry) removeServer(id string, o origin) (bool, error) {
+	var e Event
+	r.GameServerMapLock.Lock()
+	before, ok := r.GameServerMap[id]
+	if ok {
+		delete(r.GameServerMap, id)
+		e = r.sequence(newEvent(EventServerRemoved, id, &before, GameServer{}, o))
+	}
+	r.GameServerMapLock.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+	return true, r.emit(e)
+}
@@ -0,0 +1,216 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// adminRequest sends a request to the given handler with the given admin token,
+// and returns the response.
+func adminRequest(h http.Handler, token, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdmin_Auth(t *testing.T) {
+	disabled := NewRegistry(Config{}).(*registry).newHTTPHandler()
+	if rec := adminRequest(disabled, "", http.MethodGet, "/admin/bans", ""); rec.Code != http.StatusNotFound {
+		t.Logf("expected admin endpoints to be disabled, got status %d", rec.Code)
+		t.FailNow()
+	}
+
+	h := NewRegistry(Config{AdminToken: "secret"}).(*registry).newHTTPHandler()
+	for token, expected := range map[string]int{
+		"":       http.StatusUnauthorized,
+		"wrong":  http.StatusUnauthorized,
+		"secret": http.StatusOK,
+	} {
+		if rec := adminRequest(h, token, http.MethodGet, "/admin/bans", ""); rec.Code != expected {
+			t.Logf("token %q: expected status %d, got %d", token, expected, rec.Code)
+			t.FailNow()
+		}
+	}
+}
+
+func TestAdmin_Bans(t *testing.T) {
+	reg := NewRegistry(Config{AdminToken: "secret"}).(*registry)
+	reg.GameServerMap["203.0.113.7:6777"] = GameServer{Name: "Banned", IP: "203.0.113.7", Port: 6777}
+	reg.GameServerMap["203.0.113.8:6777"] = GameServer{Name: "Allowed", IP: "203.0.113.8", Port: 6777}
+	h := reg.newHTTPHandler()
+
+	rec := adminRequest(h, "secret", http.MethodPost, "/admin/bans", `{"kind":"ip","value":"203.0.113.7","reason":"abuse"}`)
+	if rec.Code != http.StatusCreated {
+		t.Logf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+		t.FailNow()
+	}
+	if _, ok := reg.GameServerMap["203.0.113.7:6777"]; ok || reg.ServerCount() != 1 {
+		t.Log("expected banned server to be removed")
+		t.FailNow()
+	}
+
+	rec = adminRequest(h, "secret", http.MethodPost, "/admin/bans", `{"kind":"ip","value":"nonsense"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Logf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+		t.FailNow()
+	}
+
+	rec = adminRequest(h, "secret", http.MethodGet, "/admin/bans", "")
+	if !strings.Contains(rec.Body.String(), `"reason":"abuse"`) {
+		t.Logf("expected ban in list, got %s", rec.Body)
+		t.FailNow()
+	}
+
+	for _, expected := range []int{http.StatusNoContent, http.StatusNotFound} {
+		rec = adminRequest(h, "secret", http.MethodDelete, "/admin/bans?kind=ip&value=203.0.113.7", "")
+		if rec.Code != expected {
+			t.Logf("expected status %d, got %d", expected, rec.Code)
+			t.FailNow()
+		}
+	}
+}
+
+func TestAdmin_EditServer(t *testing.T) {
+	const mode = "RGM_TerroristHuntCoopMode"
+	beaconPort := startTestBeacon(t, "Reported Name", mode)
+	id := fmt.Sprintf("127.0.0.1:%d", beaconPort-1000)
+
+	reg := NewRegistry(Config{AdminToken: "secret", HealthcheckTimeout: time.Second}).(*registry)
+	reg.GameServerMap[id] = GameServer{Name: "Old Name", IP: "127.0.0.1", Port: beaconPort - 1000, BeaconPort: beaconPort}
+	h := reg.newHTTPHandler()
+
+	rec := adminRequest(h, "secret", http.MethodPatch, "/admin/servers/"+id, `{"name":"Admin Name","pinned":true}`)
+	if rec.Code != http.StatusOK {
+		t.Logf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		t.FailNow()
+	}
+	if _, ok := filterHealthyServers(reg.servers())[id]; !ok {
+		t.Log("expected pinned server to be listed while unhealthy")
+		t.FailNow()
+	}
+
+	// Healthchecks don't rename pinned servers.
+	rec = adminRequest(h, "secret", http.MethodPost, "/admin/servers/"+id+"/healthcheck", "")
+	if rec.Code != http.StatusOK || reg.GameServerMap[id].Name != "Admin Name" || reg.GameServerMap[id].Health.PassedChecks != 1 {
+		t.Logf("expected pinned server to keep its name after a healthcheck, got %d: %s", rec.Code, rec.Body)
+		t.FailNow()
+	}
+
+	adminRequest(h, "secret", http.MethodPatch, "/admin/servers/"+id, `{"hidden":true}`)
+	if _, ok := filterUnexpiredServers(reg.servers())[id]; ok {
+		t.Log("expected hidden server not to be listed")
+		t.FailNow()
+	}
+
+	if rec := adminRequest(h, "secret", http.MethodPatch, "/admin/servers/"+id, `{"port":1}`); rec.Code != http.StatusBadRequest {
+		t.Logf("expected status %d for an uneditable field, got %d", http.StatusBadRequest, rec.Code)
+		t.FailNow()
+	}
+
+	for _, expected := range []int{http.StatusNoContent, http.StatusNotFound} {
+		if rec := adminRequest(h, "secret", http.MethodDelete, "/admin/servers/"+id, ""); rec.Code != expected {
+			t.Logf("expected status %d, got %d", expected, rec.Code)
+			t.FailNow()
+		}
+	}
+}
+
+func TestAdmin_EditServerDuringRegistration(t *testing.T) {
+	const mode = "RGM_TerroristHuntCoopMode"
+	conn, beaconPort := listenTestBeacon(t)
+	id := fmt.Sprintf("127.0.0.1:%d", beaconPort-1000)
+	report := newTestReport("Reported Name", beaconPort-1000, beaconPort, mode)
+
+	reg := NewRegistry(Config{AdminToken: "secret", HealthcheckTimeout: time.Second}).(*registry)
+	reg.GameServerMap[id] = GameServer{Name: "Old Name", IP: "127.0.0.1", Port: beaconPort - 1000, BeaconPort: beaconPort}
+	h := reg.newHTTPHandler()
+
+	// The server is edited by an admin while the registration probe is waiting
+	// for its response.
+	go func() {
+		buf := make([]byte, 4096)
+		_, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		adminRequest(h, "secret", http.MethodPatch, "/admin/servers/"+id, `{"name":"Admin Name","pinned":true,"hidden":true}`)
+		conn.WriteToUDP(report, addr)
+	}()
+
+	if err := reg.AddServer("127.0.0.1", report); err != nil {
+		t.Log("failed to add server:", err)
+		t.FailNow()
+	}
+	if s := reg.servers()[id]; !s.Pinned || !s.Hidden || s.Name != "Admin Name" {
+		t.Logf("expected registration to keep changes made during the probe, got %+v", s)
+		t.FailNow()
+	}
+}
+
+func TestAdmin_EditServerDuringHealthcheck(t *testing.T) {
+	conn, beaconPort := listenTestBeacon(t)
+	id := fmt.Sprintf("127.0.0.1:%d", beaconPort-1000)
+
+	reg := NewRegistry(Config{
+		AdminToken:                    "secret",
+		HealthcheckTimeout:            200 * time.Millisecond,
+		HealthcheckUnhealthyThreshold: 60,
+		HealthcheckHiddenThreshold:    5760,
+	}).(*registry)
+	reg.GameServerMap[id] = GameServer{Name: "Old Name", IP: "127.0.0.1", Port: beaconPort - 1000, BeaconPort: beaconPort}
+	h := reg.newHTTPHandler()
+
+	// The server is edited by an admin while the healthcheck is waiting for a
+	// response which never arrives.
+	go func() {
+		buf := make([]byte, 4096)
+		if _, _, err := conn.ReadFromUDP(buf); err != nil {
+			return
+		}
+		adminRequest(h, "secret", http.MethodPatch, "/admin/servers/"+id, `{"name":"Admin Name","hidden":true}`)
+	}()
+
+	server, ok := reg.healthcheckServer(id, origin{channel: ChannelHealthcheck}, func(GameServer) {}, func(GameServer) {})
+	if !ok || server != reg.servers()[id] {
+		t.Logf("expected the current server to be returned, got %+v", server)
+		t.FailNow()
+	}
+	if !server.Hidden || server.Name != "Admin Name" || server.Health.FailedChecks != 1 {
+		t.Logf("expected healthcheck to keep changes made during the probe, got %+v", server)
+		t.FailNow()
+	}
+}
+
+func TestAdmin_ReloadSeed(t *testing.T) {
+	seedPath := filepath.Join(t.TempDir(), "seed.csv")
+	seed := "name,ip,port,mode\nKnown,203.0.113.7,6777,coop\nNew,203.0.113.8,6777,coop"
+	if err := os.WriteFile(seedPath, []byte(seed), 0644); err != nil {
+		t.Log("failed to write seed file:", err)
+		t.FailNow()
+	}
+
+	reg := NewRegistry(Config{AdminToken: "secret", SeedPath: seedPath}).(*registry)
+	known := GameServer{Name: "Known", IP: "203.0.113.7", Port: 6777, GameMode: "coop"}
+	known.Health.Healthy = true
+	reg.GameServerMap["203.0.113.7:6777"] = known
+
+	rec := adminRequest(reg.newHTTPHandler(), "secret", http.MethodPost, "/admin/seed", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"added":1,"skipped":0}` {
+		t.Logf("unexpected response %d: %s", rec.Code, rec.Body)
+		t.FailNow()
+	}
+	if reg.ServerCount() != 2 || !reg.GameServerMap["203.0.113.7:6777"].Health.Healthy {
+		t.Log("expected seed servers to be merged without changing known servers")
+		t.FailNow()
+	}
+}
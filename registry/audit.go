@@ -0,0 +1,146 @@
+package registry
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Channel identifies how a change to the server list was requested.
+type Channel string
+
+// Channels through which the server list is changed.
+const (
+	ChannelUDP         Channel = "udp"         // A beacon sent by the server.
+	ChannelHTTP        Channel = "http"        // A request to /servers/add.
+	ChannelSeed        Channel = "seed"        // Loading or reloading the seed file.
+	ChannelStore       Channel = "store"       // Loading the checkpoint or Store.
+	ChannelAdmin       Channel = "admin"       // A request to an admin endpoint.
+	ChannelHealthcheck Channel = "healthcheck" // A scheduled healthcheck.
+)
+
+const (
+	// defaultAuditLogSize is used when Config.AuditLogSize is zero.
+	defaultAuditLogSize = 10000
+
+	// defaultAuditLogMaxBytes is used when Config.AuditLogMaxBytes is zero.
+	defaultAuditLogMaxBytes = 64 << 20
+)
+
+// origin describes who requested a change to the server list.
+type origin struct {
+	channel  Channel
+	sourceIP string
+}
+
+// auditLog keeps the most recent Events in memory, and appends every Event to
+// a file when one is configured.
+type auditLog struct {
+	lock   sync.RWMutex
+	events []Event // Ring buffer, oldest first from next.
+	next   int
+	full   bool
+	file   *journal
+}
+
+func newAuditLog(size int, path string, maxBytes int) *auditLog {
+	if size <= 0 {
+		size = defaultAuditLogSize
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultAuditLogMaxBytes
+	}
+	a := &auditLog{events: make([]Event, size)}
+	if path != "" {
+		a.file = newJournal(path)
+		a.file.maxSize = int64(maxBytes)
+	}
+	return a
+}
+
+// Record adds the given Event to the audit log.
+func (a *auditLog) Record(e Event) error {
+	a.lock.Lock()
+	a.events[a.next] = e
+	a.next = (a.next + 1) % len(a.events)
+	if a.next == 0 {
+		a.full = true
+	}
+	a.lock.Unlock()
+
+	if a.file != nil {
+		return a.file.Append(e)
+	}
+	return nil
+}
+
+// Query returns the recorded Events for the given server ID between since and
+// until, oldest first. Empty or zero values match every Event. When the audit
+// log has a file, it and its rotated file are searched instead of the Events
+// kept in memory, without blocking new Events from being recorded.
+func (a *auditLog) Query(serverID string, since, until time.Time) ([]Event, error) {
+	matches := []Event{}
+	match := func(e Event) {
+		if serverID != "" && e.ServerID != serverID {
+			return
+		}
+		if (!since.IsZero() && e.Time.Before(since)) || (!until.IsZero() && e.Time.After(until)) {
+			return
+		}
+		matches = append(matches, e)
+	}
+
+	if a.file != nil {
+		if err := a.file.Scan(match); err != nil {
+			return nil, err
+		}
+		return matches, nil
+	}
+
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.full {
+		for _, e := range a.events[a.next:] {
+			match(e)
+		}
+	}
+	for _, e := range a.events[:a.next] {
+		match(e)
+	}
+	return matches, nil
+}
+
+// auditQuery handles requests to the audit log endpoint. The server, since and
+// until query parameters filter the results, with times in RFC 3339 format.
+func (r *registry) auditQuery(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("request method must be GET"))
+		return
+	}
+
+	var (
+		query = req.URL.Query()
+		times [2]time.Time
+	)
+	for i, name := range []string{"since", "until"} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(name + " must be an RFC 3339 timestamp"))
+				return
+			}
+			times[i] = t
+		}
+	}
+
+	events, err := r.audit.Query(query.Get("server"), times[0], times[1])
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("failed to read audit log: " + err.Error()))
+		return
+	}
+	writeJSONValue(w, http.StatusOK, map[string][]Event{"events": events})
+}
@@ -0,0 +1,103 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditLog_Ring(t *testing.T) {
+	a := newAuditLog(3, "", 0)
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, id := range []string{"a", "b", "a", "b", "a"} {
+		a.Record(Event{Time: start.Add(time.Duration(i) * time.Minute), ServerID: id})
+	}
+
+	events, _ := a.Query("", time.Time{}, time.Time{})
+	if len(events) != 3 || !events[0].Time.Equal(start.Add(2*time.Minute)) {
+		t.Logf("expected the 3 most recent events, oldest first, got %+v", events)
+		t.FailNow()
+	}
+
+	events, _ = a.Query("a", start.Add(3*time.Minute), time.Time{})
+	if len(events) != 1 || !events[0].Time.Equal(start.Add(4*time.Minute)) {
+		t.Logf("expected 1 event matching the filter, got %+v", events)
+		t.FailNow()
+	}
+}
+
+func TestAuditLog_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit")
+	a := newAuditLog(1, path, 0)
+	a.Record(Event{Time: time.Now(), ServerID: "a"})
+	a.Record(Event{Time: time.Now(), ServerID: "b"})
+
+	// Events are read from the file, so the in-memory size doesn't apply.
+	events, err := newAuditLog(1, path, 0).Query("", time.Time{}, time.Time{})
+	if err != nil || len(events) != 2 {
+		t.Logf("expected 2 events from file, got %d, %v", len(events), err)
+		t.FailNow()
+	}
+}
+
+func TestAuditLog_FileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit")
+	a := newAuditLog(1, path, 1) // Rotate after every Event.
+	for _, id := range []string{"a", "b", "c"} {
+		a.Record(Event{Time: time.Now(), ServerID: id})
+	}
+
+	// Only the current and previous files are kept.
+	events, err := a.Query("", time.Time{}, time.Time{})
+	if err != nil || len(events) != 1 || events[0].ServerID != "c" {
+		t.Logf("expected the most recent event from the rotated file, got %+v, %v", events, err)
+		t.FailNow()
+	}
+	a.Record(Event{Time: time.Now(), ServerID: "d"})
+	if events, _ := a.Query("", time.Time{}, time.Time{}); len(events) != 1 || events[0].ServerID != "d" {
+		t.Logf("expected only the newest rotated event, got %+v", events)
+		t.FailNow()
+	}
+}
+
+func TestAdmin_Audit(t *testing.T) {
+	const mode = "RGM_TerroristHuntCoopMode"
+	beaconPort := startTestBeacon(t, "Audited", mode)
+	reg := NewRegistry(Config{AdminToken: "secret", HealthcheckTimeout: time.Second}).(*registry)
+	if err := reg.AddServer("127.0.0.1", newTestReport("Audited", beaconPort-1000, beaconPort, mode)); err != nil {
+		t.Log("failed to add server:", err)
+		t.FailNow()
+	}
+
+	h := reg.newHTTPHandler()
+	id := fmt.Sprintf("127.0.0.1:%d", beaconPort-1000)
+	adminRequest(h, "secret", http.MethodPatch, "/admin/servers/"+id, `{"name":"Renamed"}`)
+
+	rec := adminRequest(h, "secret", http.MethodGet, "/admin/audit?server="+id, "")
+	var response struct {
+		Events []Event `json:"events"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || len(response.Events) != 2 {
+		t.Logf("expected 2 events, got %d: %s", rec.Code, rec.Body)
+		t.FailNow()
+	}
+
+	added, edited := response.Events[0], response.Events[1]
+	if added.Type != EventServerAdded || added.Channel != ChannelUDP || added.SourceIP != "127.0.0.1" || added.Before != nil {
+		t.Logf("unexpected registration event: %+v", added)
+		t.FailNow()
+	}
+	if edited.Channel != ChannelAdmin || edited.Before == nil || edited.Before.Name != "Audited" || edited.Server.Name != "Renamed" {
+		t.Logf("unexpected edit event: %+v", edited)
+		t.FailNow()
+	}
+
+	rec = adminRequest(h, "secret", http.MethodGet, "/admin/audit?since=yesterday", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Logf("expected status %d for an invalid time, got %d", http.StatusBadRequest, rec.Code)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,312 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BanKind identifies what a Ban is matched against.
+type BanKind string
+
+// Kinds of Bans supported by the registry.
+const (
+	BanIP       BanKind = "ip"       // A single IP address, such as 203.0.113.7.
+	BanCIDR     BanKind = "cidr"     // A range of IP addresses, such as 203.0.113.0/24.
+	BanHostPort BanKind = "hostport" // A single server, such as 203.0.113.7:6777.
+	BanName     BanKind = "name"     // A regular expression matched against server names.
+)
+
+// Ban prevents matching servers from being registered, and removes matching
+// servers which are already registered.
+type Ban struct {
+	Kind    BanKind   `json:"kind"`
+	Value   string    `json:"value"`
+	Reason  string    `json:"reason,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+var (
+	errBanned     = errors.New("server is banned")
+	errInvalidBan = errors.New("invalid ban")
+)
+
+// banList holds the current Bans, and saves them to a JSON file at path after
+// every change when path is set.
+type banList struct {
+	path string
+	lock sync.RWMutex
+	bans []compiledBan
+}
+
+// compiledBan is a Ban with its value parsed for matching.
+type compiledBan struct {
+	Ban
+	match func(ip netip.Addr, port int, name string) bool
+}
+
+func newBanList(path string) *banList {
+	return &banList{path: path}
+}
+
+// Load replaces the current Bans with those in the ban list file. A missing
+// file is treated as an empty ban list. Invalid Bans are skipped, and returned
+// as an error after the remaining Bans have been loaded.
+func (l *banList) Load() error {
+	if l.path == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var bans []Ban
+	if err := json.Unmarshal(b, &bans); err != nil {
+		return err
+	}
+
+	var (
+		compiled []compiledBan
+		errs     []error
+	)
+	for _, ban := range bans {
+		c, err := compileBan(ban)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("skipping ban %q: %w", ban.Value, err))
+			continue
+		}
+		compiled = append(compiled, c)
+	}
+
+	l.lock.Lock()
+	l.bans = compiled
+	l.lock.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// List returns the current Bans, sorted by kind and value.
+func (l *banList) List() []Ban {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	bans := make([]Ban, len(l.bans))
+	for i, c := range l.bans {
+		bans[i] = c.Ban
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		if bans[i].Kind != bans[j].Kind {
+			return bans[i].Kind < bans[j].Kind
+		}
+		return bans[i].Value < bans[j].Value
+	})
+	return bans
+}
+
+// Add adds the given Ban, replacing any existing Ban of the same kind and value,
+// and returns the Ban as stored. IP addresses are stored in canonical form.
+func (l *banList) Add(ban Ban) (Ban, error) {
+	c, err := compileBan(ban)
+	if err != nil {
+		return Ban{}, err
+	}
+	if c.Created.IsZero() {
+		c.Created = time.Now().UTC()
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	bans := []compiledBan{c}
+	for _, existing := range l.bans {
+		if existing.Kind != c.Kind || existing.Value != c.Value {
+			bans = append(bans, existing)
+		}
+	}
+	l.bans = bans
+
+	return c.Ban, l.save()
+}
+
+// Remove removes the Ban with the given kind and value, and returns false if
+// there was no such Ban.
+func (l *banList) Remove(kind BanKind, value string) (bool, error) {
+	if c, err := compileBan(Ban{Kind: kind, Value: value}); err == nil {
+		value = c.Value // Match the canonical form used by Add.
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var (
+		bans    []compiledBan
+		removed bool
+	)
+	for _, existing := range l.bans {
+		if existing.Kind == kind && existing.Value == value {
+			removed = true
+			continue
+		}
+		bans = append(bans, existing)
+	}
+	if !removed {
+		return false, nil
+	}
+	l.bans = bans
+
+	return true, l.save()
+}
+
+// Match returns the first Ban matching a server with the given IP, port and
+// name. Name Bans are not checked when name is empty, so that servers can be
+// checked before their name is known.
+func (l *banList) Match(ip string, port int, name string) (Ban, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Ban{}, false
+	}
+	addr = addr.Unmap()
+
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	for _, c := range l.bans {
+		if c.match(addr, port, name) {
+			return c.Ban, true
+		}
+	}
+	return Ban{}, false
+}
+
+// save writes the current Bans to the ban list file. The caller must hold the
+// lock.
+func (l *banList) save() error {
+	if l.path == "" {
+		return nil
+	}
+
+	bans := make([]Ban, len(l.bans))
+	for i, c := range l.bans {
+		bans[i] = c.Ban
+	}
+	b, err := json.MarshalIndent(bans, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(l.path, append(b, '\n'), 0)
+}
+
+// compileBan validates the given Ban and prepares it for matching. Errors wrap
+// errInvalidBan.
+func compileBan(ban Ban) (compiledBan, error) {
+	c, err := parseBan(ban)
+	if err != nil {
+		return compiledBan{}, fmt.Errorf("%w: %v", errInvalidBan, err)
+	}
+	return c, nil
+}
+
+// parseBan parses the value of the given Ban according to its kind.
+func parseBan(ban Ban) (compiledBan, error) {
+	switch ban.Kind {
+	case BanIP:
+		addr, err := netip.ParseAddr(ban.Value)
+		if err != nil {
+			return compiledBan{}, err
+		}
+		addr = addr.Unmap()
+		ban.Value = addr.String()
+		return compiledBan{Ban: ban, match: func(ip netip.Addr, _ int, _ string) bool {
+			return ip == addr
+		}}, nil
+
+	case BanCIDR:
+		prefix, err := netip.ParsePrefix(ban.Value)
+		if err != nil {
+			return compiledBan{}, err
+		}
+		prefix = prefix.Masked()
+		ban.Value = prefix.String()
+		return compiledBan{Ban: ban, match: func(ip netip.Addr, _ int, _ string) bool {
+			return prefix.Contains(ip)
+		}}, nil
+
+	case BanHostPort:
+		hostport, err := netip.ParseAddrPort(ban.Value)
+		if err != nil {
+			return compiledBan{}, err
+		}
+		hostport = netip.AddrPortFrom(hostport.Addr().Unmap(), hostport.Port())
+		ban.Value = hostport.String()
+		return compiledBan{Ban: ban, match: func(ip netip.Addr, port int, _ string) bool {
+			return ip == hostport.Addr() && port == int(hostport.Port())
+		}}, nil
+
+	case BanName:
+		re, err := regexp.Compile(ban.Value)
+		if err != nil {
+			return compiledBan{}, err
+		}
+		return compiledBan{Ban: ban, match: func(_ netip.Addr, _ int, name string) bool {
+			return name != "" && re.MatchString(name)
+		}}, nil
+	}
+
+	return compiledBan{}, fmt.Errorf("unknown kind %q", ban.Kind)
+}
+
+// checkBans returns an error wrapping errBanned if a server with the given IP,
+// port and name matches a Ban.
+func (r *registry) checkBans(ip string, port int, name string) error {
+	if ban, ok := r.bans.Match(ip, port, name); ok {
+		return fmt.Errorf("%w: %s %s", errBanned, ban.Kind, ban.Value)
+	}
+	return nil
+}
+
+// removeBannedServers removes every server which matches a Ban.
+func (r *registry) removeBannedServers(o origin) {
+	var events []Event
+	r.GameServerMapLock.Lock()
+	for id, server := range r.GameServerMap {
+		if _, ok := r.bans.Match(server.IP, server.Port, server.Name); ok {
+			delete(r.GameServerMap, id)
+			before := server // Each Event keeps a pointer to its own copy.
+			events = append(events, r.sequence(newEvent(EventServerRemoved, id, &before, GameServer{}, o)))
+		}
+	}
+	r.GameServerMapLock.Unlock()
+
+	for _, e := range events {
+		r.emit(e)
+	}
+}
+
+// removeBannedServer removes the given server if it is banned.
+func (r *registry) removeBannedServer(id string, o origin) {
+	var e Event
+	r.GameServerMapLock.Lock()
+	before, ok := r.GameServerMap[id]
+	if ok {
+		if _, ok = r.bans.Match(before.IP, before.Port, before.Name); ok {
+			delete(r.GameServerMap, id)
+			e = r.sequence(newEvent(EventServerRemoved, id, &before, GameServer{}, o))
+		}
+	}
+	r.GameServerMapLock.Unlock()
+
+	if ok {
+		r.emit(e)
+	}
+}
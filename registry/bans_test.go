@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBanList_Match(t *testing.T) {
+	l := newBanList("")
+	for _, ban := range []Ban{
+		{Kind: BanIP, Value: "203.0.113.7"},
+		{Kind: BanCIDR, Value: "198.51.100.77/24"},
+		{Kind: BanHostPort, Value: "192.0.2.1:6777"},
+		{Kind: BanName, Value: `(?i)cheat`},
+	} {
+		if _, err := l.Add(ban); err != nil {
+			t.Log("failed to add ban:", err)
+			t.FailNow()
+		}
+	}
+
+	cases := []struct {
+		ip     string
+		port   int
+		name   string
+		banned bool
+	}{
+		{"203.0.113.7", 6777, "Server", true},
+		{"::ffff:203.0.113.7", 6777, "Server", true},
+		{"203.0.113.8", 6777, "Server", false},
+		{"198.51.100.200", 6777, "Server", true},
+		{"192.0.2.1", 6777, "Server", true},
+		{"192.0.2.1", 6778, "Server", false},
+		{"192.0.2.2", 6777, "Free CHEATS", true},
+		{"192.0.2.2", 6777, "", false},
+	}
+	for _, c := range cases {
+		if _, banned := l.Match(c.ip, c.port, c.name); banned != c.banned {
+			t.Logf("%s:%d %q: expected banned=%v", c.ip, c.port, c.name, c.banned)
+			t.FailNow()
+		}
+	}
+}
+
+func TestBanList_Invalid(t *testing.T) {
+	l := newBanList("")
+	for _, ban := range []Ban{
+		{Kind: BanIP, Value: "not an ip"},
+		{Kind: BanCIDR, Value: "203.0.113.7"},
+		{Kind: BanHostPort, Value: "203.0.113.7"},
+		{Kind: BanName, Value: "("},
+		{Kind: "country", Value: "NZ"},
+	} {
+		if _, err := l.Add(ban); !errors.Is(err, errInvalidBan) {
+			t.Logf("%s %q: expected errInvalidBan, got %v", ban.Kind, ban.Value, err)
+			t.FailNow()
+		}
+	}
+	if len(l.List()) != 0 {
+		t.Log("expected invalid bans not to be added")
+		t.FailNow()
+	}
+}
+
+func TestBanList_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	l := newBanList(path)
+	if _, err := l.Add(Ban{Kind: BanCIDR, Value: "198.51.100.77/24", Reason: "spam"}); err != nil {
+		t.Log("failed to add ban:", err)
+		t.FailNow()
+	}
+	if _, err := l.Add(Ban{Kind: BanIP, Value: "203.0.113.7"}); err != nil {
+		t.Log("failed to add ban:", err)
+		t.FailNow()
+	}
+
+	// Values are stored in canonical form, so they can be removed using either.
+	if removed, err := l.Remove(BanCIDR, "198.51.100.0/24"); !removed || err != nil {
+		t.Logf("expected ban to be removed, got %v, %v", removed, err)
+		t.FailNow()
+	}
+
+	loaded := newBanList(path)
+	if err := loaded.Load(); err != nil {
+		t.Log("failed to load bans:", err)
+		t.FailNow()
+	}
+	bans := loaded.List()
+	if len(bans) != 1 || bans[0].Value != "203.0.113.7" || bans[0].Created.IsZero() {
+		t.Logf("unexpected bans after reload: %+v", bans)
+		t.FailNow()
+	}
+}
+
+func TestAddServer_Banned(t *testing.T) {
+	const mode = "RGM_TerroristHuntCoopMode"
+	beaconPort := startTestBeacon(t, "Banned", mode)
+	reg := NewRegistry(Config{HealthcheckTimeout: 100 * time.Millisecond}).(*registry)
+	reg.bans.Add(Ban{Kind: BanName, Value: "^Banned$"})
+
+	err := reg.AddServer("127.0.0.1", newTestReport("Banned", beaconPort-1000, beaconPort, mode))
+	if !errors.Is(err, errBanned) || reg.ServerCount() != 0 {
+		t.Logf("expected banned server to be rejected, got %v", err)
+		t.FailNow()
+	}
+}
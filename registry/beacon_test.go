@@ -0,0 +1,62 @@
+package registry
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// newTestReport returns the bytes of a REPORT beacon for a server with the
+// given name, ports and game mode.
+func newTestReport(name string, port, beaconPort int, mode string) []byte {
+	const pilcrow = 182 // Red Storm field separator, as a single byte.
+
+	fields := [][2]string{
+		{"P1", fmt.Sprint(port)},
+		{"I1", name},
+		{"F1", mode},
+		{"G2", fmt.Sprint(beaconPort)},
+		{"D2", "PATCH 1.60 (build 412)"},
+		{"O2", strings.Repeat("Welcome! ", 10)}, // Pad to the minimum report size.
+	}
+
+	report := []byte(fmt.Sprintf("rvnshld %d KEYWORD  ", port))
+	for _, f := range fields {
+		report = append(report, pilcrow)
+		report = append(report, []byte(f[0]+" "+f[1]+" ")...)
+	}
+	return report
+}
+
+// startTestBeacon starts a fake game server beacon on loopback, which responds
+// to every datagram with a REPORT beacon. The beacon port is returned.
+func startTestBeacon(t *testing.T, name string, mode string) int {
+	conn, beaconPort := listenTestBeacon(t)
+	report := newTestReport(name, beaconPort-1000, beaconPort, mode)
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			_, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteToUDP(report, addr)
+		}
+	}()
+
+	return beaconPort
+}
+
+// listenTestBeacon opens a UDP socket on loopback for a fake game server beacon
+// which the test responds to itself, and returns it along with its port.
+func listenTestBeacon(t *testing.T) (*net.UDPConn, int) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Log("failed to start test beacon:", err)
+		t.FailNow()
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, conn.LocalAddr().(*net.UDPAddr).Port
+}
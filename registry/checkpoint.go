@@ -0,0 +1,106 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic replaces the file at path with data, such that a crash or
+// write failure never leaves a partially-written file behind. The data is
+// written and synced to a temporary file in the same directory, which is then
+// renamed into place. Before the rename, up to the given number of previous
+// versions of the file are kept as path.1 (newest) through path.N (oldest),
+// without path itself ever being missing.
+func writeFileAtomic(path string, data []byte, rotations int) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := os.CreateTemp(dir, base+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op after a successful rename.
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := rotateFiles(path, rotations); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	// Persist the rename itself. Directories cannot be synced on all platforms,
+	// so failures here are ignored.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
+
+// rotateFiles shifts path.1 through path.N-1 to path.2 through path.N, and
+// copies path to path.1. Path itself is left in place, so that it can be
+// replaced atomically. Missing files are skipped.
+func rotateFiles(path string, rotations int) error {
+	if rotations <= 0 {
+		return nil
+	}
+	for i := rotations - 1; i >= 1; i-- {
+		from := rotatedPath(path, i)
+		if err := os.Rename(from, rotatedPath(path, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return linkFile(path, rotatedPath(path, 1))
+}
+
+// linkFile atomically replaces the file at to with a hard link to the file at
+// from, or with a copy of it where hard links are not supported. Nothing is
+// done when from does not exist.
+func linkFile(from, to string) error {
+	tmp := to + ".tmp"
+	os.Remove(tmp) // Left behind by a crash.
+	if err := os.Link(from, tmp); err != nil {
+		b, err := os.ReadFile(from)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(tmp, b, 0644); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp, to)
+}
+
+// rotatedPath returns the path of the nth previous version of the file at path.
+// The current version is returned for n=0.
+func rotatedPath(path string, n int) string {
+	if n == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s.%d", path, n)
+}
@@ -0,0 +1,103 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic_Rotations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.csv")
+	for _, data := range []string{"one", "two", "three", "four"} {
+		if err := writeFileAtomic(path, []byte(data), 2); err != nil {
+			t.Log("failed to write file:", err)
+			t.FailNow()
+		}
+	}
+
+	for n, expected := range []string{"four", "three", "two"} {
+		b, err := os.ReadFile(rotatedPath(path, n))
+		if err != nil {
+			t.Log("failed to read file:", err)
+			t.FailNow()
+		}
+		if string(b) != expected {
+			t.Logf("expected %s, got %s", expected, string(b))
+			t.FailNow()
+		}
+	}
+
+	if _, err := os.Stat(rotatedPath(path, 3)); !os.IsNotExist(err) {
+		t.Log("expected no more than 2 rotations")
+		t.FailNow()
+	}
+
+	// The current file is kept in place while it is rotated.
+	if err := rotateFiles(path, 2); err != nil {
+		t.Log("failed to rotate files:", err)
+		t.FailNow()
+	}
+	for _, p := range []string{path, rotatedPath(path, 1)} {
+		if b, err := os.ReadFile(p); err != nil || string(b) != "four" {
+			t.Logf("expected %s to contain the current version, got %q: %v", p, b, err)
+			t.FailNow()
+		}
+	}
+
+	// No temporary files should remain.
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp*"))
+	if len(matches) != 0 {
+		t.Log("unexpected temporary files:", matches)
+		t.FailNow()
+	}
+}
+
+func TestLoadServers_FallbackToRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.csv")
+	config := Config{CheckpointPath: path, CheckpointRotations: 2}
+
+	good := "name,ip,port,mode\nMyServer,127.0.0.1,6777,adv"
+	if err := writeFileAtomic(path, []byte(good), config.CheckpointRotations); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	// Simulate a truncated write by a previous version of the registry.
+	if err := writeFileAtomic(path, []byte("name,ip,po"), config.CheckpointRotations); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	reg := NewRegistry(config)
+	if err := reg.LoadServers(path); err != nil {
+		t.Log("failed to load servers:", err)
+		t.FailNow()
+	}
+	if reg.ServerCount() != 1 {
+		t.Logf("incorrect server count; expected %d, got %d", 1, reg.ServerCount())
+		t.FailNow()
+	}
+}
+
+func TestLoadServers_NoFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.csv")
+	reg := NewRegistry(Config{CheckpointRotations: 2})
+	if err := reg.LoadServers(path); !os.IsNotExist(err) {
+		t.Log("expected a not-exist error, got:", err)
+		t.FailNow()
+	}
+}
+
+func TestLoadServers_NoRotationsForOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	seedPath := filepath.Join(dir, "seed.csv")
+	if err := os.WriteFile(rotatedPath(seedPath, 1), []byte("name,ip,port,mode\nOld,127.0.0.1,6777,adv"), 0644); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	reg := NewRegistry(Config{CheckpointPath: filepath.Join(dir, "checkpoint.csv"), CheckpointRotations: 2})
+	if err := reg.LoadServers(seedPath); !os.IsNotExist(err) || reg.ServerCount() != 0 {
+		t.Log("expected rotated seed files to be ignored, got:", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,87 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrentAccess exercises registration, healthchecks, saving and HTTP
+// reads at the same time. Run with -race to detect unsynchronized access.
+func TestConcurrentAccess(t *testing.T) {
+	beaconPort := startTestBeacon(t, "Hammer", "RGM_TerroristHuntCoopMode")
+	dir := t.TempDir()
+
+	reg := NewRegistry(Config{
+		CheckpointPath:                filepath.Join(dir, "checkpoint.csv"),
+		CheckpointRotations:           2,
+		HealthcheckTimeout:            time.Second,
+		HealthcheckHealthyThreshold:   1,
+		HealthcheckUnhealthyThreshold: 2,
+		HealthcheckHiddenThreshold:    4,
+	}).(*registry)
+	handler := reg.newHTTPHandler()
+
+	const (
+		workers    = 4
+		iterations = 10
+	)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(4)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				port := 2000 + w*iterations + i
+				data := newTestReport("Hammer", port, beaconPort, "RGM_TerroristHuntCoopMode")
+				if err := reg.AddServer("127.0.0.1", data); err != nil {
+					t.Error("failed to add server:", err)
+				}
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				reg.SendHealthchecks(func(GameServer) {}, func(GameServer) {})
+			}
+		}()
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				path := reg.Config.CheckpointPath
+				if i%2 == 0 {
+					path = filepath.Join(dir, "export.csv")
+				}
+				if err := reg.SaveServers(path); err != nil {
+					t.Error("failed to save servers:", err)
+				}
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				for _, path := range []string{"/servers", "/servers/all", "/servers/debug"} {
+					handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+				}
+				_ = reg.ServerCount()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reg.ServerCount() != workers*iterations {
+		t.Logf("incorrect server count; expected %d, got %d", workers*iterations, reg.ServerCount())
+		t.FailNow()
+	}
+
+	// The exported file should be readable as a complete checkpoint.
+	exported := NewRegistry(Config{StrictLoading: true})
+	if err := exported.LoadServers(filepath.Join(dir, "export.csv")); err != nil {
+		t.Log("failed to load exported servers:", err)
+		t.FailNow()
+	}
+}
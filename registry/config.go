@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"log/slog"
 	"time"
 )
 
@@ -10,11 +11,131 @@ type Config struct {
 	CheckpointPath     string
 	CheckpointInterval time.Duration
 
+	// CheckpointRotations is the number of previous checkpoint files to keep.
+	// LoadServers falls back to these files, newest first, when the current
+	// file cannot be read.
+	CheckpointRotations int
+
+	// Store persists the server list. When nil, a CSV store at CheckpointPath
+	// is used. When set, servers added to the Store by other registries are
+	// loaded after each checkpoint.
+	Store Store
+
+	// JournalPath is the path of an append-only log of changes made since the
+	// last checkpoint, which is replayed on startup. When empty, changes made
+	// since the last checkpoint are lost if the registry stops unexpectedly.
+	JournalPath string
+
+	// ExpiredRetention is how long expired servers are kept before they are
+	// removed from the server list. Expired servers are kept forever when zero.
+	ExpiredRetention time.Duration
+
+	// GraveyardPath is the path of a file to which removed expired servers are
+	// appended. When empty, removed servers are not archived.
+	GraveyardPath string
+
+	// BanListPath is the path of a JSON file containing banned servers, which
+	// is updated whenever bans are changed through the admin API. When empty,
+	// bans are kept in memory only.
+	BanListPath string
+
+	// AuditLogPath is the path of a file to which every change to the server
+	// list is appended, for querying through the admin API. When empty, only
+	// the most recent AuditLogSize changes are kept, in memory. AuditLogSize
+	// defaults to 10000 when zero. The file is rotated when it reaches
+	// AuditLogMaxBytes, keeping one previous file, so at most twice that is
+	// kept on disk. AuditLogMaxBytes defaults to 64 MiB when zero.
+	AuditLogPath     string
+	AuditLogSize     int
+	AuditLogMaxBytes int
+
+	// HistoryPath is the path of a file to which recent healthcheck results are
+	// saved at each checkpoint, for calculating uptime. When empty, results are
+	// only kept in memory.
+	HistoryPath string
+
+	// StrictLoading causes LoadServers to fail on the first malformed line,
+	// instead of skipping malformed lines and loading the rest of the file.
+	StrictLoading bool
+
 	HealthcheckInterval           time.Duration
 	HealthcheckTimeout            time.Duration
 	HealthcheckHealthyThreshold   int
 	HealthcheckUnhealthyThreshold int
 	HealthcheckHiddenThreshold    int
 
-	ListenAddr string
+	// HealthcheckUnhealthyInterval is the time between healthchecks of
+	// unhealthy servers, and HealthcheckExpiredInterval is the initial time
+	// between healthchecks of expired servers. The time between healthchecks of
+	// an expired server grows with the time it has been expired, doubling with
+	// each check, up to HealthcheckMaxInterval. HealthcheckInterval is used for
+	// healthy servers. Each interval defaults to the one before it when zero,
+	// and HealthcheckMaxInterval defaults to 24 hours.
+	HealthcheckUnhealthyInterval time.Duration
+	HealthcheckExpiredInterval   time.Duration
+	HealthcheckMaxInterval       time.Duration
+
+	// HealthcheckUnhealthyAfter and HealthcheckExpiredAfter are how long a
+	// server's healthchecks must fail before it is marked unhealthy or expired.
+	// When zero, HealthcheckUnhealthyThreshold and HealthcheckHiddenThreshold
+	// respectively give the number of failed healthchecks instead.
+	HealthcheckUnhealthyAfter time.Duration
+	HealthcheckExpiredAfter   time.Duration
+
+	// HealthcheckConcurrency is the maximum number of healthchecks in progress
+	// at once. Defaults to 64 when zero. Changes require a restart.
+	HealthcheckConcurrency int
+
+	// HTTPListenAddr and UDPListenAddr are the addresses on which HTTP requests
+	// and UDP beacons are received, in the form "host:port". The host may be an
+	// IPv6 address in brackets, and listens on all interfaces when empty. Both
+	// default to ":8080" when empty.
+	HTTPListenAddr string
+	UDPListenAddr  string
+
+	// TrustProxyHeaders causes the client IP of HTTP requests to be read from
+	// the X-Forwarded-For header. The header is always used for requests from
+	// loopback addresses, so this is only needed for a reverse proxy on another
+	// host, and should only be enabled when HTTPListenAddr can only be reached
+	// through that proxy.
+	TrustProxyHeaders bool
+
+	// AdminToken is the bearer token required by the /admin endpoints. Admin
+	// endpoints are disabled when empty.
+	AdminToken string
+
+	// RateLimitInterval is the average time between registrations allowed from
+	// a single source IP, and separately for a single server. Up to
+	// RateLimitSourceBurst and RateLimitServerBurst registrations respectively
+	// are allowed at once. Rate limiting is disabled when any value is zero.
+	RateLimitInterval    time.Duration
+	RateLimitSourceBurst int
+	RateLimitServerBurst int
+
+	// RegistrationDedupWindow is how recently a healthy server must have been
+	// seen for repeated registrations to skip the healthcheck.
+	RegistrationDedupWindow time.Duration
+
+	// RegistrationVerification controls how new servers are verified before
+	// being added. Defaults to VerifyNone when empty.
+	RegistrationVerification VerificationPolicy
+
+	// UDPWorkers is the number of beacons processed concurrently, and
+	// UDPQueueSize is the number of beacons which may wait for a worker before
+	// further beacons are dropped. Defaults are used when zero.
+	UDPWorkers   int
+	UDPQueueSize int
+
+	// ShutdownTimeout limits how long Run waits for in-flight work to finish
+	// after being stopped. Defaults to 30 seconds when zero.
+	ShutdownTimeout time.Duration
+
+	// LoadConfig is called by Reload to read the new configuration. Settings
+	// used to open files and listeners keep their original values. When nil,
+	// Reload only reloads the seed file.
+	LoadConfig func() (Config, error)
+
+	// Logger receives the registry's log messages. Defaults to slog.Default()
+	// when nil.
+	Logger *slog.Logger
 }
@@ -1,30 +1,70 @@
 package registry
 
 import (
+	"bytes"
+	"encoding/csv"
 	"errors"
 	"fmt"
+	"io"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // CSVSerializer provides an interface for serializing and deserializing lists
 // of OpenRVS servers as CSV bytes.
 type CSVSerializer interface {
 	Serialize(GameServerMap) []byte
+	SerializeCheckpoint(GameServerMap) []byte
 	Deserialize([]byte) (GameServerMap, error)
 	EnableDebug(bool)
+	EnableLenient(bool)
+}
+
+var (
+	errInvalidLine      = errors.New("invalid line in csv input")
+	errInvalidPort      = errors.New("invalid (non-numeric) port received")
+	errMissingColumns   = errors.New("csv header is missing required columns")
+	errInvalidTimestamp = errors.New("invalid timestamp received")
+)
+
+// LineError describes a single line of CSV input which could not be parsed.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e LineError) Unwrap() error {
+	return e.Err
+}
+
+// LineErrors is returned when one or more lines of CSV input were skipped while
+// deserializing in lenient mode. All other lines were loaded successfully.
+type LineErrors []LineError
+
+func (e LineErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%d invalid lines in csv input, first error: %v", len(e), e[0])
 }
 
 // csvSerializer implements the CSVSerializer interface.
 type csvSerializer struct {
-	headerLine string
-	debugMode  bool
+	headerLine  string
+	debugMode   bool
+	lenientMode bool
 }
 
 // NewCSVSerializer initializes and returns a CSVSerializer. The debugMode
 // parameter control whether or not health check status is included in
-// serialized output.
+// serialized output, and the lenientMode parameter controls whether or not
+// malformed lines are skipped during deserialization.
 func NewCSVSerializer() CSVSerializer {
 	return &csvSerializer{
 		headerLine: "name,ip,port,mode",
@@ -36,29 +76,41 @@ func (c *csvSerializer) EnableDebug(value bool) {
 	c.debugMode = value
 }
 
-// Serialize writes the given GameServerMap as sorted CSV output.
+func (c *csvSerializer) EnableLenient(value bool) {
+	c.lenientMode = value
+}
+
+// Serialize writes the given GameServerMap as sorted CSV output. Fields are
+// quoted according to RFC 4180 only when necessary, so lines for servers with
+// plain names are identical to the unquoted format expected by OpenRVS.
 func (c *csvSerializer) Serialize(m GameServerMap) []byte {
 	lines := []string{c.headerLine}
 
 	var serverLines []string
 	for _, server := range m {
-		line := fmt.Sprintf(
-			"%s,%s,%d,%s",
+		record := []string{
 			server.Name,
 			server.IP,
-			server.Port,
+			strconv.Itoa(server.Port),
 			server.GameMode,
-		)
+		}
 		if c.debugMode {
-			line += fmt.Sprintf(
-				",healthy=%v,expired=%v,passed=%d,failed=%d",
-				server.Health.Healthy,
-				server.Health.Expired,
-				server.Health.PassedChecks,
-				server.Health.FailedChecks,
+			record = append(record,
+				fmt.Sprintf("healthy=%v", server.Health.Healthy),
+				fmt.Sprintf("expired=%v", server.Health.Expired),
+				fmt.Sprintf("passed=%d", server.Health.PassedChecks),
+				fmt.Sprintf("failed=%d", server.Health.FailedChecks),
 			)
+			if server.Stats != nil {
+				record = append(record,
+					"uptime_24h="+formatStat(server.Stats.Uptime24h, "%"),
+					"uptime_7d="+formatStat(server.Stats.Uptime7d, "%"),
+					"uptime_30d="+formatStat(server.Stats.Uptime30d, "%"),
+					"latency="+formatStat(server.Stats.LatencyMS, "ms"),
+				)
+			}
 		}
-		serverLines = append(serverLines, line)
+		serverLines = append(serverLines, encodeCSVRecord(record))
 	}
 
 	// Previous implementation sorted non-alphanumeric server names last:
@@ -71,38 +123,278 @@ func (c *csvSerializer) Serialize(m GameServerMap) []byte {
 	return []byte(strings.Join(lines, "\n"))
 }
 
+// SerializeCheckpoint writes the given GameServerMap as sorted CSV output,
+// including every column needed to restore the full server state.
+func (c *csvSerializer) SerializeCheckpoint(m GameServerMap) []byte {
+	header := make([]string, len(checkpointColumns))
+	for i, column := range checkpointColumns {
+		header[i] = column.name
+	}
+	lines := []string{strings.Join(header, ",")}
+
+	var serverLines []string
+	for _, server := range m {
+		record := make([]string, len(checkpointColumns))
+		for i, column := range checkpointColumns {
+			record[i] = column.format(server)
+		}
+		serverLines = append(serverLines, encodeCSVRecord(record))
+	}
+	sort.Strings(serverLines)
+	lines = append(lines, serverLines...)
+
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// Deserialize reads a GameServerMap from CSV input. Both the four-column
+// format written by Serialize and the checkpoint format written by
+// SerializeCheckpoint are accepted. In strict mode, the first malformed line
+// causes a LineError to be returned. In lenient mode, malformed lines are
+// skipped and reported together as LineErrors alongside the servers which were
+// parsed successfully.
 func (c *csvSerializer) Deserialize(b []byte) (GameServerMap, error) {
-	servers := make(GameServerMap)
+	var (
+		servers = make(GameServerMap)
+		columns = legacyColumns
+		errs    LineErrors
+	)
 
-	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
-	for _, line := range lines {
-		// Don't attempt to deserialize the header line.
-		if strings.HasPrefix(line, c.headerLine) {
-			continue
+	reader := csv.NewReader(bytes.NewReader(b))
+	reader.FieldsPerRecord = -1 // Field counts are validated below.
+	reader.LazyQuotes = true    // Older files may contain unescaped quotes.
+
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
 		}
 
-		// Don't attempt to deserialize malformed lines.
-		fields := strings.Split(line, ",")
-		if len(fields) != 4 {
-			return nil, errors.New("invalid line in csv input")
+		var line int
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			line = parseErr.StartLine
+			err = parseErr.Err
+		} else {
+			line, _ = reader.FieldPos(0)
 		}
 
-		// Convert port to integer.
-		ip := fields[1]
-		port, err := strconv.Atoi(fields[2])
-		if err != nil {
-			return nil, errors.New("invalid (non-numeric) port received")
+		if err == nil {
+			// Header lines select the columns used for subsequent lines.
+			if isCSVHeader(fields) {
+				columns, err = lookupColumns(fields)
+				if err == nil {
+					continue
+				}
+				// Without valid columns, no further lines can be parsed.
+				columns = nil
+			} else {
+				var server GameServer
+				server, err = parseCSVRecord(columns, fields)
+				if err == nil {
+					hostport := fmt.Sprintf("%s:%d", server.IP, server.Port)
+					servers[hostport] = server
+					continue
+				}
+			}
 		}
 
-		// Save a new GameServer in the GameServerMap.
-		hostport := fmt.Sprintf("%s:%d", ip, port)
-		servers[hostport] = GameServer{
-			Name:     fields[0],
-			IP:       ip,
-			Port:     port,
-			GameMode: strings.TrimSuffix(fields[3], "\n"),
+		if !c.lenientMode {
+			return nil, LineError{Line: line, Err: err}
 		}
+		errs = append(errs, LineError{Line: line, Err: err})
 	}
 
+	if len(errs) > 0 {
+		return servers, errs
+	}
 	return servers, nil
 }
+
+// parseCSVRecord converts the fields from a single line of CSV input to a
+// GameServer, using the given columns.
+func parseCSVRecord(columns []*checkpointColumn, fields []string) (GameServer, error) {
+	// Don't attempt to deserialize malformed lines.
+	if columns == nil || len(fields) != len(columns) {
+		return GameServer{}, errInvalidLine
+	}
+
+	var server GameServer
+	for i, column := range columns {
+		if column == nil {
+			continue // Ignore unknown columns from newer versions.
+		}
+		if err := column.parse(&server, fields[i]); err != nil {
+			return GameServer{}, err
+		}
+	}
+	return server, nil
+}
+
+// formatStat formats an optional statistic for debug output, with one decimal
+// place and the given unit.
+func formatStat(value *float64, unit string) string {
+	if value == nil {
+		return "n/a"
+	}
+	return strconv.FormatFloat(*value, 'f', 1, 64) + unit
+}
+
+// encodeCSVRecord returns a single RFC 4180 CSV line for the given fields,
+// without a trailing newline. Unlike csv.Writer, fields are only quoted when
+// they contain a comma, quote or line break, so that other fields (such as
+// names with leading spaces) are written exactly as before.
+func encodeCSVRecord(fields []string) string {
+	encoded := make([]string, len(fields))
+	for i, field := range fields {
+		if strings.ContainsAny(field, ",\"\r\n") {
+			field = `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+		}
+		encoded[i] = field
+	}
+	return strings.Join(encoded, ",")
+}
+
+// checkpointColumn describes a single column of CSV output, with functions to
+// convert between the column value and a GameServer field.
+type checkpointColumn struct {
+	name   string
+	format func(GameServer) string
+	parse  func(*GameServer, string) error
+}
+
+// checkpointColumns lists the columns of checkpoint files in the order they are
+// written. Checkpoint files are versioned by their header line: version 1 files
+// contain only the "name,ip,port,mode" columns, while version 2 files contain
+// every column below. Columns are matched by name when reading, so columns may
+// be added to the end of this list without breaking older files.
+var checkpointColumns = []checkpointColumn{
+	{
+		name:   "name",
+		format: func(s GameServer) string { return s.Name },
+		parse:  func(s *GameServer, v string) error { s.Name = v; return nil },
+	},
+	{
+		name:   "ip",
+		format: func(s GameServer) string { return s.IP },
+		parse:  func(s *GameServer, v string) error { s.IP = v; return nil },
+	},
+	{
+		name:   "port",
+		format: func(s GameServer) string { return strconv.Itoa(s.Port) },
+		parse: func(s *GameServer, v string) (err error) {
+			if s.Port, err = strconv.Atoi(v); err != nil {
+				return errInvalidPort
+			}
+			return nil
+		},
+	},
+	{
+		name:   "mode",
+		format: func(s GameServer) string { return s.GameMode },
+		parse:  func(s *GameServer, v string) error { s.GameMode = v; return nil },
+	},
+	intColumn("beacon_port", func(s *GameServer) *int { return &s.BeaconPort }),
+	boolColumn("healthy", func(s *GameServer) *bool { return &s.Health.Healthy }),
+	boolColumn("expired", func(s *GameServer) *bool { return &s.Health.Expired }),
+	intColumn("passed_checks", func(s *GameServer) *int { return &s.Health.PassedChecks }),
+	intColumn("failed_checks", func(s *GameServer) *int { return &s.Health.FailedChecks }),
+	boolColumn("parse_failed", func(s *GameServer) *bool { return &s.Health.ParseFailed }),
+	timeColumn("first_seen", func(s *GameServer) *time.Time { return &s.FirstSeen }),
+	timeColumn("last_seen", func(s *GameServer) *time.Time { return &s.LastSeen }),
+	timeColumn("expired_at", func(s *GameServer) *time.Time { return &s.ExpiredAt }),
+	boolColumn("hidden", func(s *GameServer) *bool { return &s.Hidden }),
+	boolColumn("pinned", func(s *GameServer) *bool { return &s.Pinned }),
+	timeColumn("failing_since", func(s *GameServer) *time.Time { return &s.Health.FailingSince }),
+}
+
+// legacyColumns are the columns used by files with no header line, which
+// predate versioned checkpoints.
+var legacyColumns = []*checkpointColumn{
+	&checkpointColumns[0],
+	&checkpointColumns[1],
+	&checkpointColumns[2],
+	&checkpointColumns[3],
+}
+
+// isCSVHeader returns true when the given fields are a header line.
+func isCSVHeader(fields []string) bool {
+	return len(fields) >= len(legacyColumns) && fields[0] == "name" && fields[1] == "ip" && fields[2] == "port"
+}
+
+// lookupColumns returns the columns named in the given header line. Unknown
+// column names result in nil entries.
+func lookupColumns(header []string) ([]*checkpointColumn, error) {
+	columns := make([]*checkpointColumn, len(header))
+	found := 0
+	for i, name := range header {
+		for j := range checkpointColumns {
+			if checkpointColumns[j].name == name {
+				columns[i] = &checkpointColumns[j]
+				if j < len(legacyColumns) {
+					found++
+				}
+				break
+			}
+		}
+	}
+	if found != len(legacyColumns) {
+		return nil, errMissingColumns
+	}
+	return columns, nil
+}
+
+func intColumn(name string, field func(*GameServer) *int) checkpointColumn {
+	return checkpointColumn{
+		name:   name,
+		format: func(s GameServer) string { return strconv.Itoa(*field(&s)) },
+		parse: func(s *GameServer, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*field(s) = n
+			return nil
+		},
+	}
+}
+
+func boolColumn(name string, field func(*GameServer) *bool) checkpointColumn {
+	return checkpointColumn{
+		name:   name,
+		format: func(s GameServer) string { return strconv.FormatBool(*field(&s)) },
+		parse: func(s *GameServer, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*field(s) = b
+			return nil
+		},
+	}
+}
+
+// timeColumn formats timestamps as RFC 3339 in UTC. Zero timestamps are written
+// as empty strings.
+func timeColumn(name string, field func(*GameServer) *time.Time) checkpointColumn {
+	return checkpointColumn{
+		name: name,
+		format: func(s GameServer) string {
+			if t := *field(&s); !t.IsZero() {
+				return t.UTC().Format(time.RFC3339)
+			}
+			return ""
+		},
+		parse: func(s *GameServer, v string) error {
+			if v == "" {
+				*field(s) = time.Time{}
+				return nil
+			}
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return errInvalidTimestamp
+			}
+			*field(s) = t
+			return nil
+		},
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestCSVSerializer_New(t *testing.T) {
@@ -101,3 +102,174 @@ func TestCSVSerializer_DeserializeInvalid(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestCSVSerializer_RoundTripQuoted(t *testing.T) {
+	csv := NewCSVSerializer()
+	input := GameServerMap{
+		"127.0.0.1:6777": GameServer{
+			Name:     "Tango, Down",
+			IP:       "127.0.0.1",
+			Port:     6777,
+			GameMode: "coop",
+		},
+		"127.0.0.1:7777": GameServer{
+			Name:     `The "Best" Server`,
+			IP:       "127.0.0.1",
+			Port:     7777,
+			GameMode: "adv",
+		},
+	}
+
+	b := csv.Serialize(input)
+	expected := strings.Join([]string{
+		"name,ip,port,mode",
+		`"Tango, Down",127.0.0.1,6777,coop`,
+		`"The ""Best"" Server",127.0.0.1,7777,adv`,
+	}, "\n")
+	if string(b) != expected {
+		t.Log("unexpected csv output")
+		t.Logf("expected %s, got %s", expected, string(b))
+		t.FailNow()
+	}
+
+	output, err := csv.Deserialize(b)
+	if err != nil {
+		t.Log("failed to deserialize csv:", err)
+		t.FailNow()
+	}
+	for id, server := range input {
+		if output[id] != server {
+			t.Logf("expected %+v, got %+v", server, output[id])
+			t.FailNow()
+		}
+	}
+}
+
+func TestCSVSerializer_DeserializeLegacyQuotes(t *testing.T) {
+	csv := NewCSVSerializer()
+	servers, err := csv.Deserialize([]byte(`Joe's "Fun" Server,127.0.0.1,6777,adv`))
+	if err != nil {
+		t.Log("failed to deserialize csv:", err)
+		t.FailNow()
+	}
+
+	expected := `Joe's "Fun" Server`
+	if servers["127.0.0.1:6777"].Name != expected {
+		t.Logf("expected %s, got %s", expected, servers["127.0.0.1:6777"].Name)
+		t.FailNow()
+	}
+}
+
+func TestCSVSerializer_DeserializeStrictLineError(t *testing.T) {
+	csv := NewCSVSerializer()
+	_, err := csv.Deserialize([]byte("name,ip,port,mode\nGood,127.0.0.1,6777,adv\nBad,127.0.0.1,port,adv"))
+
+	lineErr, ok := err.(LineError)
+	if !ok {
+		t.Logf("expected LineError, got %T", err)
+		t.FailNow()
+	}
+	if lineErr.Line != 3 {
+		t.Logf("expected line %d, got %d", 3, lineErr.Line)
+		t.FailNow()
+	}
+}
+
+func TestCSVSerializer_DeserializeLenient(t *testing.T) {
+	csv := NewCSVSerializer()
+	csv.EnableLenient(true)
+
+	input := strings.Join([]string{
+		"name,ip,port,mode",
+		"Good,127.0.0.1,6777,adv",
+		"Missing,127.0.0.1,6778",
+		"Also Good,127.0.0.1,6779,coop",
+		"Bad Port,127.0.0.1,port,coop",
+	}, "\n")
+	servers, err := csv.Deserialize([]byte(input))
+
+	if len(servers) != 2 {
+		t.Logf("expected %d servers, got %d", 2, len(servers))
+		t.FailNow()
+	}
+
+	lineErrs, ok := err.(LineErrors)
+	if !ok {
+		t.Logf("expected LineErrors, got %T", err)
+		t.FailNow()
+	}
+	if len(lineErrs) != 2 || lineErrs[0].Line != 3 || lineErrs[1].Line != 5 {
+		t.Logf("unexpected line errors: %v", lineErrs)
+		t.FailNow()
+	}
+}
+
+func TestCSVSerializer_CheckpointRoundTrip(t *testing.T) {
+	csv := NewCSVSerializer()
+	input := GameServerMap{
+		"127.0.0.1:6777": GameServer{
+			Name:       "Tango, Down",
+			IP:         "127.0.0.1",
+			Port:       6777,
+			BeaconPort: 7776,
+			GameMode:   "coop",
+			Health: GameServerHealthStatus{
+				Healthy:      true,
+				PassedChecks: 12,
+			},
+			FirstSeen: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			LastSeen:  time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC),
+		},
+		"127.0.0.1:7777": GameServer{
+			Name:       "MyServer",
+			IP:         "127.0.0.1",
+			Port:       7777,
+			BeaconPort: 8777,
+			GameMode:   "adv",
+			Health: GameServerHealthStatus{
+				Expired:      true,
+				FailedChecks: 6000,
+				ParseFailed:  true,
+			},
+			ExpiredAt: time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC),
+		},
+	}
+
+	b := csv.SerializeCheckpoint(input)
+	expected := "name,ip,port,mode,beacon_port,healthy,expired,passed_checks,failed_checks,parse_failed,first_seen,last_seen,expired_at,hidden,pinned,failing_since"
+	if header := strings.Split(string(b), "\n")[0]; header != expected {
+		t.Log("unexpected header line")
+		t.Logf("expected %s, got %s", expected, header)
+		t.FailNow()
+	}
+
+	output, err := csv.Deserialize(b)
+	if err != nil {
+		t.Log("failed to deserialize checkpoint:", err)
+		t.FailNow()
+	}
+	if len(output) != len(input) {
+		t.Logf("expected %d servers, got %d", len(input), len(output))
+		t.FailNow()
+	}
+	for id, server := range input {
+		if output[id] != server {
+			t.Logf("expected %+v, got %+v", server, output[id])
+			t.FailNow()
+		}
+	}
+}
+
+func TestCSVSerializer_DeserializeUnknownColumns(t *testing.T) {
+	csv := NewCSVSerializer()
+	servers, err := csv.Deserialize([]byte("name,ip,port,mode,future_column,healthy\nMyServer,127.0.0.1,6777,adv,x,true"))
+	if err != nil {
+		t.Log("failed to deserialize csv:", err)
+		t.FailNow()
+	}
+
+	if !servers["127.0.0.1:6777"].Health.Healthy {
+		t.Log("expected healthy column to be parsed")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,199 @@
+package registry
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of change made to the server list.
+type EventType string
+
+// Types of Events emitted by the registry.
+const (
+	EventServerAdded   EventType = "added"
+	EventHealthChanged EventType = "health_changed"
+	EventServerRenamed EventType = "renamed"
+	EventServerEdited  EventType = "edited"
+	EventServerExpired EventType = "expired"
+	EventServerRemoved EventType = "removed"
+	EventServerPruned  EventType = "pruned"
+)
+
+// Event describes a single change to the server list. Server contains the
+// complete state of the server after the change, and is empty for removals.
+// For pruned servers, Server contains the final state before removal. Before
+// contains the state of the server before the change, and is nil for servers
+// which were not previously known. Channel and SourceIP describe who requested
+// the change, where known.
+type Event struct {
+	Time     time.Time   `json:"time"`
+	Type     EventType   `json:"type"`
+	ServerID string      `json:"server_id"`
+	Server   GameServer  `json:"server"`
+	Before   *GameServer `json:"before,omitempty"`
+	Channel  Channel     `json:"channel,omitempty"`
+	SourceIP string      `json:"source_ip,omitempty"`
+
+	ticket uint64 // Set by sequence; zero for Events which are not ordered.
+}
+
+// newEvent returns an Event for a change requested by the given origin.
+func newEvent(eventType EventType, id string, before *GameServer, server GameServer, o origin) Event {
+	return Event{
+		Type:     eventType,
+		ServerID: id,
+		Server:   server,
+		Before:   before,
+		Channel:  o.channel,
+		SourceIP: o.sourceIP,
+	}
+}
+
+// sequence takes the next ticket for the server changed by the given Event, and
+// must be called while GameServerMapLock is held for writing, so that tickets
+// follow the order in which changes are applied. Every sequenced Event must be
+// passed to emit, since later Events for the same server wait for it.
+func (r *registry) sequence(e Event) Event {
+	e.ticket = r.sequencer.ticket(e.ServerID)
+	return e
+}
+
+// emit records a change which has already been applied to GameServerMap, by
+// adding it to the audit log, appending it to the journal and persisting it in
+// the Store. The healthcheck history of removed servers is discarded. Failures
+// are logged as well as returned, since most callers have nowhere to report them.
+// Sequenced Events are recorded after any earlier Events for the same server.
+func (r *registry) emit(e Event) error {
+	if e.ticket != 0 {
+		r.sequencer.wait(e.ServerID, e.ticket)
+		defer r.sequencer.done(e.ServerID, e.ticket)
+	}
+	e.Time = time.Now()
+	r.logger.Debug("server list changed",
+		"event", e.Type,
+		"server_id", e.ServerID,
+		"channel", e.Channel,
+		"source_ip", e.SourceIP,
+	)
+
+	err := r.recordEvent(e)
+	if err != nil {
+		r.logger.Error("failed to record change", "event", e.Type, "server_id", e.ServerID, "error", err)
+	}
+	return err
+}
+
+// emitLoaded records a change made by loading servers which were already
+// persisted, such as a checkpoint or the Store. It is only added to the audit
+// log, since writing it to the journal or the Store again would either repeat
+// it or overwrite newer changes when the journal is replayed.
+func (r *registry) emitLoaded(e Event) {
+	e.Time = time.Now()
+	r.logger.Debug("server list changed", "event", e.Type, "server_id", e.ServerID, "channel", e.Channel)
+	if err := r.audit.Record(e); err != nil {
+		r.logger.Error("failed to record change", "event", e.Type, "server_id", e.ServerID, "error", err)
+	}
+}
+
+// recordEvent writes the given Event to the audit log, the journal and the
+// Store.
+func (r *registry) recordEvent(e Event) error {
+	auditErr := r.audit.Record(e)
+
+	if r.journal != nil {
+		if err := r.journal.Append(e); err != nil {
+			return errors.Join(err, auditErr)
+		}
+	}
+
+	var err error
+	if isRemoval(e.Type) {
+		r.history.Remove(e.ServerID)
+		err = r.Store.Delete(e.ServerID)
+	} else {
+		err = r.Store.Upsert(e.ServerID, e.Server)
+	}
+	return errors.Join(err, auditErr)
+}
+
+// eventSequencer orders the recording of Events for each server, so that the
+// journal and the Store are written in the same order as changes were applied
+// to GameServerMap, without writing them while GameServerMapLock is held.
+type eventSequencer struct {
+	lock   sync.Mutex
+	cond   *sync.Cond
+	issued map[string]uint64 // Last ticket taken for each server.
+	served map[string]uint64 // Last ticket recorded for each server.
+}
+
+func newEventSequencer() *eventSequencer {
+	s := &eventSequencer{
+		issued: make(map[string]uint64),
+		served: make(map[string]uint64),
+	}
+	s.cond = sync.NewCond(&s.lock)
+	return s
+}
+
+// ticket returns the next ticket for the given server, starting at 1.
+func (s *eventSequencer) ticket(id string) uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.issued[id]++
+	return s.issued[id]
+}
+
+// wait blocks until the Events for every earlier ticket for the given server
+// have been recorded.
+func (s *eventSequencer) wait(id string, ticket uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for s.served[id] != ticket-1 {
+		s.cond.Wait()
+	}
+}
+
+// done marks the Event for the given ticket as recorded. Servers with no
+// outstanding tickets are forgotten.
+func (s *eventSequencer) done(id string, ticket uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.issued[id] == ticket {
+		delete(s.issued, id)
+		delete(s.served, id)
+	} else {
+		s.served[id] = ticket
+	}
+	s.cond.Broadcast()
+}
+
+// healthEvents returns the types of Events caused by a healthcheck which
+// changed a server from before to after.
+func healthEvents(before, after GameServer) []EventType {
+	var events []EventType
+	if before.Health.Healthy != after.Health.Healthy {
+		events = append(events, EventHealthChanged)
+	}
+	if !before.Health.Expired && after.Health.Expired {
+		events = append(events, EventServerExpired)
+	}
+	if before.Name != after.Name || before.GameMode != after.GameMode {
+		events = append(events, EventServerRenamed)
+	}
+	return events
+}
+
+// applyEvent applies a replayed Event to the given GameServerMap.
+func applyEvent(servers GameServerMap, e Event) {
+	if isRemoval(e.Type) {
+		delete(servers, e.ServerID)
+		return
+	}
+	servers[e.ServerID] = e.Server
+}
+
+// isRemoval returns true for Events which remove a server from the list.
+func isRemoval(eventType EventType) bool {
+	return eventType == EventServerRemoved || eventType == EventServerPruned
+}
@@ -0,0 +1,52 @@
+package registry
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEmit_SequencedPerServer(t *testing.T) {
+	reg := NewRegistry(Config{JournalPath: filepath.Join(t.TempDir(), "journal")}).(*registry)
+	o := origin{channel: ChannelAdmin}
+
+	// Two changes to the same server are applied in order, but the goroutine
+	// which applied the first is slower to record it.
+	reg.GameServerMapLock.Lock()
+	first := reg.sequence(newEvent(EventServerEdited, "127.0.0.1:6777", nil, GameServer{Name: "First"}, o))
+	second := reg.sequence(newEvent(EventServerEdited, "127.0.0.1:6777", nil, GameServer{Name: "Second"}, o))
+	other := reg.sequence(newEvent(EventServerEdited, "127.0.0.1:7777", nil, GameServer{Name: "Other"}, o))
+	reg.GameServerMapLock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		reg.emit(second)
+		close(done)
+	}()
+
+	// Changes to other servers don't wait.
+	reg.emit(other)
+	select {
+	case <-done:
+		t.Log("expected the second change to wait for the first")
+		t.FailNow()
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	reg.emit(first)
+	<-done
+
+	events, err := reg.journal.Read()
+	if err != nil || len(events) != 3 {
+		t.Logf("expected 3 journaled events, got %d: %v", len(events), err)
+		t.FailNow()
+	}
+	if events[0].Server.Name != "Other" || events[1].Server.Name != "First" || events[2].Server.Name != "Second" {
+		t.Logf("expected changes to be journaled in the order they were applied, got %+v", events)
+		t.FailNow()
+	}
+	if len(reg.sequencer.issued) != 0 || len(reg.sequencer.served) != 0 {
+		t.Log("expected servers with no outstanding changes to be forgotten")
+		t.FailNow()
+	}
+}
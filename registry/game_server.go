@@ -1,25 +1,49 @@
 package registry
 
+import "time"
+
 // GameServerMap maps unique server IDs to server metadata.
 type GameServerMap map[string]GameServer
 
 // GameServer contains all relevant fields for an individual game server.
 type GameServer struct {
-	Name       string
-	IP         string
-	Port       int
-	BeaconPort int
-	GameMode   string
+	Name       string `json:"name"`
+	IP         string `json:"ip"`
+	Port       int    `json:"port"`
+	BeaconPort int    `json:"beacon_port"`
+	GameMode   string `json:"mode"`
+
+	Health GameServerHealthStatus `json:"health"`
+
+	// FirstSeen is the time the server was first registered, and LastSeen is
+	// the time of the most recent successful healthcheck or, for healthy
+	// servers, the most recent registration beacon.
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	// ExpiredAt is the time the server was marked as expired, and is zero for
+	// servers which are not expired.
+	ExpiredAt time.Time `json:"expired_at"`
+
+	// Hidden servers are never listed, while Pinned servers are listed even
+	// when unhealthy or expired, and are never pruned. Both are set by admins.
+	// Healthchecks don't change the name or game mode of pinned servers.
+	Hidden bool `json:"hidden"`
+	Pinned bool `json:"pinned"`
 
-	Health GameServerHealthStatus
+	// Stats summarizes recent healthchecks. It is only set in server lists
+	// returned by the HTTP endpoints, and is not saved.
+	Stats *ServerStats `json:"stats,omitempty"`
 }
 
 // GameServerHealthStatus contains information needed to track whether a server
-// is healthy.
+// is healthy. FailingSince is the start time of the first of the current run of
+// failed healthchecks, and is zero when the last healthcheck passed.
 type GameServerHealthStatus struct {
-	Healthy      bool
-	Expired      bool
-	PassedChecks int
-	FailedChecks int
-	ParseFailed  bool
+	Healthy      bool      `json:"healthy"`
+	Expired      bool      `json:"expired"`
+	PassedChecks int       `json:"passed_checks"`
+	FailedChecks int       `json:"failed_checks"`
+	FailingSince time.Time `json:"failing_since"`
+	ParseFailed  bool      `json:"parse_failed"`
 }
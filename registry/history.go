@@ -0,0 +1,235 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// Healthcheck results are kept at two resolutions: 5-minute buckets for the
+// last day, and hourly buckets for the last 30 days.
+const (
+	fineBucketWidth     = 5 * time.Minute
+	fineBucketRetention = 24 * time.Hour
+
+	coarseBucketWidth     = time.Hour
+	coarseBucketRetention = 30 * 24 * time.Hour
+)
+
+// ServerStats summarizes the recent healthchecks of a server. Values are nil
+// when there were no healthchecks in the period. Latency and player counts are
+// averaged over successful healthchecks in the last 24 hours.
+type ServerStats struct {
+	Uptime24h *float64 `json:"uptime_24h,omitempty"` // Percentage of healthchecks passed.
+	Uptime7d  *float64 `json:"uptime_7d,omitempty"`
+	Uptime30d *float64 `json:"uptime_30d,omitempty"`
+	LatencyMS *float64 `json:"latency_ms,omitempty"`
+	Players   *float64 `json:"players,omitempty"`
+}
+
+// historyBucket summarizes the healthchecks of a server which started during a
+// single period of time.
+type historyBucket struct {
+	Start   time.Time     `json:"start"`
+	Checks  int           `json:"checks"`
+	Passed  int           `json:"passed"`
+	Latency time.Duration `json:"latency"` // Total for passed checks.
+	Players int           `json:"players"` // Total for passed checks.
+}
+
+// serverHistory contains the healthcheck results of a single server, oldest
+// first.
+type serverHistory struct {
+	Fine   []historyBucket `json:"fine"`
+	Coarse []historyBucket `json:"coarse"`
+}
+
+// history keeps the healthcheck results of every server, and saves them to a
+// JSON file at path when path is set.
+type history struct {
+	path    string
+	lock    sync.Mutex
+	servers map[string]*serverHistory
+	changed bool // Since the last Save.
+}
+
+func newHistory(path string) *history {
+	return &history{path: path, servers: make(map[string]*serverHistory)}
+}
+
+// Record adds the result of a healthcheck which started at the given time.
+func (h *history) Record(id string, start time.Time, passed bool, latency time.Duration, players int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	s, ok := h.servers[id]
+	if !ok {
+		s = &serverHistory{}
+		h.servers[id] = s
+	}
+	s.Fine = addToBuckets(s.Fine, fineBucketWidth, fineBucketRetention, start, passed, latency, players)
+	s.Coarse = addToBuckets(s.Coarse, coarseBucketWidth, coarseBucketRetention, start, passed, latency, players)
+	h.changed = true
+}
+
+// Remove discards the healthcheck results of a server.
+func (h *history) Remove(id string) {
+	h.lock.Lock()
+	delete(h.servers, id)
+	h.changed = true
+	h.lock.Unlock()
+}
+
+// Stats returns a summary of the healthchecks of a server, as of now.
+func (h *history) Stats(id string, now time.Time) ServerStats {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	s, ok := h.servers[id]
+	if !ok {
+		return ServerStats{}
+	}
+
+	day := summarize(s.Fine, now.Add(-24*time.Hour))
+	stats := ServerStats{
+		Uptime24h: day.uptime(),
+		Uptime7d:  summarize(s.Coarse, now.Add(-7*24*time.Hour)).uptime(),
+		Uptime30d: summarize(s.Coarse, now.Add(-30*24*time.Hour)).uptime(),
+	}
+	if day.Passed > 0 {
+		latency := float64(day.Latency) / float64(time.Millisecond) / float64(day.Passed)
+		players := float64(day.Players) / float64(day.Passed)
+		stats.LatencyMS, stats.Players = &latency, &players
+	}
+	return stats
+}
+
+// Load replaces the current healthcheck results with those in the history
+// file. A missing file is treated as an empty history.
+func (h *history) Load() error {
+	if h.path == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	servers := make(map[string]*serverHistory)
+	if err := json.Unmarshal(b, &servers); err != nil {
+		return err
+	}
+
+	h.lock.Lock()
+	h.servers = servers
+	h.lock.Unlock()
+	return nil
+}
+
+// Save writes the current healthcheck results to the history file, unless they
+// are unchanged since the last Save. The results are copied so that they can be
+// written without blocking Record.
+func (h *history) Save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	h.lock.Lock()
+	if !h.changed {
+		h.lock.Unlock()
+		return nil
+	}
+	servers := make(map[string]serverHistory, len(h.servers))
+	for id, s := range h.servers {
+		servers[id] = serverHistory{
+			Fine:   append([]historyBucket(nil), s.Fine...),
+			Coarse: append([]historyBucket(nil), s.Coarse...),
+		}
+	}
+	h.changed = false
+	h.lock.Unlock()
+
+	b, err := json.Marshal(servers)
+	if err == nil {
+		err = writeFileAtomic(h.path, b, 0)
+	}
+	if err != nil {
+		h.lock.Lock()
+		h.changed = true // Try again at the next Save.
+		h.lock.Unlock()
+	}
+	return err
+}
+
+// addToBuckets adds a healthcheck result to the bucket of the given width
+// containing start, and removes buckets older than retention.
+func addToBuckets(buckets []historyBucket, width, retention time.Duration, start time.Time, passed bool, latency time.Duration, players int) []historyBucket {
+	bucketStart := start.Truncate(width)
+	if n := len(buckets); n == 0 || buckets[n-1].Start.Before(bucketStart) {
+		buckets = append(buckets, historyBucket{Start: bucketStart})
+	}
+
+	// Results normally arrive in order, but may be slightly late when
+	// healthchecks take a long time.
+	for i := len(buckets) - 1; i >= 0; i-- {
+		if !buckets[i].Start.After(bucketStart) {
+			b := &buckets[i]
+			b.Checks++
+			if passed {
+				b.Passed++
+				b.Latency += latency
+				b.Players += players
+			}
+			break
+		}
+	}
+
+	cutoff := start.Add(-retention)
+	for len(buckets) > 0 && buckets[0].Start.Before(cutoff) {
+		buckets = buckets[1:]
+	}
+	return buckets
+}
+
+// summarize returns the totals of the buckets which started at or after since.
+func summarize(buckets []historyBucket, since time.Time) historyBucket {
+	var total historyBucket
+	for _, b := range buckets {
+		if b.Start.Before(since) {
+			continue
+		}
+		total.Checks += b.Checks
+		total.Passed += b.Passed
+		total.Latency += b.Latency
+		total.Players += b.Players
+	}
+	return total
+}
+
+// uptime returns the percentage of checks which passed, or nil if there were
+// no checks.
+func (b historyBucket) uptime() *float64 {
+	if b.Checks == 0 {
+		return nil
+	}
+	uptime := 100 * float64(b.Passed) / float64(b.Checks)
+	return &uptime
+}
+
+// withStats sets the Stats of the given servers, which must be a copy of the
+// server list such as the one returned by servers, and returns them.
+func (r *registry) withStats(servers GameServerMap) GameServerMap {
+	now := time.Now()
+	for id, server := range servers {
+		stats := r.history.Stats(id, now)
+		server.Stats = &stats
+		servers[id] = server
+	}
+	return servers
+}
@@ -0,0 +1,107 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistory_Stats(t *testing.T) {
+	h := newHistory("")
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	// One check every 30 minutes for 10 days, failing for the last 2 days.
+	for at := now.Add(-10 * 24 * time.Hour); at.Before(now); at = at.Add(30 * time.Minute) {
+		passed := at.Before(now.Add(-2 * 24 * time.Hour))
+		h.Record("a", at, passed, 20*time.Millisecond, 4)
+	}
+
+	stats := h.Stats("a", now)
+	if stats.Uptime24h == nil || *stats.Uptime24h != 0 {
+		t.Logf("expected 0%% uptime over 24h, got %v", stats.Uptime24h)
+		t.FailNow()
+	}
+	if stats.Uptime7d == nil || *stats.Uptime7d < 71 || *stats.Uptime7d > 72 {
+		t.Logf("expected 5/7 uptime over 7d, got %v", *stats.Uptime7d)
+		t.FailNow()
+	}
+	if stats.Uptime30d == nil || *stats.Uptime30d != 80 {
+		t.Logf("expected 8/10 uptime over 30d, got %v", *stats.Uptime30d)
+		t.FailNow()
+	}
+	if stats.LatencyMS != nil || stats.Players != nil {
+		t.Log("expected no latency or players without passed checks in 24h")
+		t.FailNow()
+	}
+
+	// Buckets older than 24 hours before the latest check are discarded.
+	if n := len(h.servers["a"].Fine); n != 49 {
+		t.Logf("expected 49 fine buckets, got %d", n)
+		t.FailNow()
+	}
+
+	if stats := h.Stats("unknown", now); stats.Uptime24h != nil {
+		t.Log("expected no stats for an unknown server")
+		t.FailNow()
+	}
+}
+
+func TestHistory_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	h := newHistory(path)
+	now := time.Now()
+	h.Record("a", now, true, 30*time.Millisecond, 6)
+	h.Record("a", now, false, 0, 0)
+	if err := h.Save(); err != nil {
+		t.Log("failed to save history:", err)
+		t.FailNow()
+	}
+
+	loaded := newHistory(path)
+	if err := loaded.Load(); err != nil {
+		t.Log("failed to load history:", err)
+		t.FailNow()
+	}
+	stats := loaded.Stats("a", now)
+	if stats.Uptime24h == nil || *stats.Uptime24h != 50 || *stats.LatencyMS != 30 || *stats.Players != 6 {
+		t.Logf("unexpected stats after reload: %+v", stats)
+		t.FailNow()
+	}
+
+	// Unchanged results are not written again.
+	os.Remove(path)
+	if err := h.Save(); err != nil {
+		t.Log("failed to save history:", err)
+		t.FailNow()
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Log("expected unchanged history not to be saved")
+		t.FailNow()
+	}
+}
+
+func TestHTTP_DebugStats(t *testing.T) {
+	reg := NewRegistry(Config{HealthcheckTimeout: 10 * time.Millisecond}).(*registry)
+	reg.GameServerMap["127.0.0.1:1"] = GameServer{Name: "Down", IP: "127.0.0.1", Port: 1, BeaconPort: 1}
+	reg.SendHealthchecks(func(GameServer) {}, func(GameServer) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/servers/debug", nil)
+	rec := httptest.NewRecorder()
+	reg.newHTTPHandler().ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), "uptime_24h=0.0%,uptime_7d=0.0%,uptime_30d=0.0%,latency=n/a") {
+		t.Logf("expected uptime in debug output, got %s", rec.Body)
+		t.FailNow()
+	}
+
+	req.Header.Set("Accept", jsonContentType)
+	rec = httptest.NewRecorder()
+	reg.newHTTPHandler().ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), `"stats":{"uptime_24h":0,"uptime_7d":0,"uptime_30d":0}`) {
+		t.Logf("expected uptime in JSON output, got %s", rec.Body)
+		t.FailNow()
+	}
+}
@@ -1,43 +1,89 @@
 package registry
 
 import (
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
 
-	beacon "github.com/willroberts/openrvs-beacon"
 	"github.com/willroberts/openrvs-registry/github"
 )
 
 func (r *registry) HandleHTTP(listenAddress string) error {
-	http.HandleFunc("/latest", func(w http.ResponseWriter, req *http.Request) {
-		w.Write(github.GetLatestReleaseVersion())
+	return http.ListenAndServe(listenAddress, r.newHTTPHandler())
+}
+
+// newHTTPHandler returns an http.Handler serving all registry endpoints.
+func (r *registry) newHTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, req *http.Request) {
+		version, err := github.LatestReleaseVersion()
+		if err != nil {
+			r.metrics.githubErrors.Add(1)
+			w.Write([]byte(fmt.Sprintf("error: %s", err.Error())))
+			return
+		}
+		w.Write([]byte(version))
 	})
 
-	http.HandleFunc("/servers", func(w http.ResponseWriter, req *http.Request) {
-		w.Write(r.CSV.Serialize(filterHealthyServers(r.GameServerMap)))
+	mux.HandleFunc("/metrics", r.serveMetrics)
+
+	mux.HandleFunc("/servers", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		servers := filterHealthyServers(r.servers())
+		if acceptsJSON(req) {
+			writeJSON(w, r.withStats(servers))
+			return
+		}
+		w.Write(r.CSV.Serialize(servers))
 	})
 
-	http.HandleFunc("/servers/all", func(w http.ResponseWriter, req *http.Request) {
-		w.Write(r.CSV.Serialize(r.GameServerMap))
+	mux.HandleFunc("/servers/all", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		servers := filterUnexpiredServers(r.servers())
+		if acceptsJSON(req) {
+			writeJSON(w, r.withStats(servers))
+			return
+		}
+		w.Write(r.CSV.Serialize(servers))
 	})
 
-	http.HandleFunc("/servers/debug", func(w http.ResponseWriter, req *http.Request) {
-		r.CSV.EnableDebug(true)
-		w.Write(r.CSV.Serialize(r.GameServerMap))
-		r.CSV.EnableDebug(false)
+	mux.HandleFunc("/servers/debug", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		// JSON output always includes health status information.
+		servers := r.withStats(r.servers())
+		if acceptsJSON(req) {
+			writeJSON(w, servers)
+			return
+		}
+		// Use a separate serializer to avoid changing the shared one while
+		// other requests are being served.
+		debugCSV := NewCSVSerializer()
+		debugCSV.EnableDebug(true)
+		w.Write(debugCSV.Serialize(servers))
 	})
 
-	http.HandleFunc("/servers/add", func(w http.ResponseWriter, req *http.Request) {
+	mux.HandleFunc("/servers/add", func(w http.ResponseWriter, req *http.Request) {
 		if req.Method != http.MethodPost {
+			r.metrics.httpRegistrations.Inc("bad_request")
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte("request method must be POST"))
 			return
 		}
 
+		if !r.sourceLimiter.Allow(clientIP(req, r.config().TrustProxyHeaders)) {
+			r.metrics.httpRegistrations.Inc("rate_limited")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("too many requests; try again later"))
+			return
+		}
+
 		body, err := io.ReadAll(req.Body)
 		if err != nil {
+			r.metrics.httpRegistrations.Inc("bad_request")
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte("failed to read request body"))
 			return
@@ -47,6 +93,7 @@ func (r *registry) HandleHTTP(listenAddress string) error {
 		// POST body should contain a string with the pattern "ip:port".
 		fields := strings.Split(string(body), ":")
 		if len(fields) != 2 {
+			r.metrics.httpRegistrations.Inc("bad_request")
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte("request body must contain 'ip:port'"))
 			return
@@ -55,36 +102,128 @@ func (r *registry) HandleHTTP(listenAddress string) error {
 		ip := fields[0]
 		port, err := strconv.Atoi(fields[1])
 		if err != nil {
+			r.metrics.httpRegistrations.Inc("bad_request")
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte("port must be a number"))
 			return
 		}
 
+		if err := r.checkBans(ip, port, ""); err != nil {
+			r.metrics.httpRegistrations.Inc("banned")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("server is banned"))
+			return
+		}
+
+		// Skip the healthcheck for servers which are already registered.
+		serverID := fmt.Sprintf("%s:%d", ip, port)
+		if r.touchServer(serverID) {
+			r.metrics.httpRegistrations.Inc("already_registered")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("server is already registered"))
+			return
+		}
+		if !r.serverLimiter.Allow(serverID) {
+			r.metrics.httpRegistrations.Inc("rate_limited")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("too many requests for this server; try again later"))
+			return
+		}
+
 		beaconPort := port + 1000
-		data, err := beacon.GetServerReport(ip, beaconPort, r.Config.HealthcheckTimeout)
-		if err != nil {
+		p := r.probe(ip, beaconPort)
+		if p.err != nil {
+			r.metrics.httpRegistrations.Inc("unreachable")
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte("failed to reach new server; ensure ServerBeaconPort is Port+1000 in RavenShield.ini"))
 			return
 		}
 
-		r.AddServer(ip, data)
+		report, err := parseRegistration(ip, p.data)
+		if err != nil {
+			r.metrics.httpRegistrations.Inc("invalid")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := r.checkBans(report.IPAddress, report.Port, report.ServerName); err != nil {
+			r.metrics.httpRegistrations.Inc("banned")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("server is banned"))
+			return
+		}
+
+		if err := r.registerServer(report, p, nil, origin{channel: ChannelHTTP, sourceIP: clientIP(req, r.config().TrustProxyHeaders)}); err != nil {
+			r.metrics.httpRegistrations.Inc("error")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		r.metrics.httpRegistrations.Inc("added")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("server added successfully"))
 	})
 
-	http.HandleFunc("/add-server", func(w http.ResponseWriter, req *http.Request) {
+	mux.HandleFunc("/add-server", func(w http.ResponseWriter, req *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(getFormHtml()))
 	})
 
-	return http.ListenAndServe(string(listenAddress), nil)
+	r.addAdminRoutes(mux)
+
+	return mux
+}
+
+// writeJSON writes the given GameServerMap as a JSON response.
+func writeJSON(w http.ResponseWriter, servers GameServerMap) {
+	b, err := serializeJSON(servers)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("failed to serialize server list"))
+		return
+	}
+	w.Header().Set("Content-Type", jsonContentType)
+	w.Write(b)
+}
+
+// clientIP returns the IP address of the client which sent the request. When
+// trustProxy is true, or the request came from a reverse proxy on the same host,
+// the address added to X-Forwarded-For by the proxy is used instead of the
+// address of the proxy itself.
+func clientIP(req *http.Request, trustProxy bool) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	if ip := net.ParseIP(host); trustProxy || (ip != nil && ip.IsLoopback()) {
+		if forwarded := req.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	return host
 }
 
+// filterHealthyServers returns the servers which should be listed by default.
 func filterHealthyServers(servers GameServerMap) GameServerMap {
 	filtered := make(GameServerMap)
 	for k, s := range servers {
-		if s.Health.Healthy {
+		if (s.Health.Healthy || s.Pinned) && !s.Hidden {
+			filtered[k] = s
+		}
+	}
+	return filtered
+}
+
+// filterUnexpiredServers returns the servers which should be listed when
+// unhealthy servers are requested.
+func filterUnexpiredServers(servers GameServerMap) GameServerMap {
+	filtered := make(GameServerMap)
+	for k, s := range servers {
+		if (!s.Health.Expired || s.Pinned) && !s.Hidden {
 			filtered[k] = s
 		}
 	}
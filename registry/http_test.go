@@ -0,0 +1,74 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/servers/add", nil)
+	req.RemoteAddr = "127.0.0.1:54321"
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 203.0.113.7")
+
+	// Requests from a proxy on the same host are always forwarded.
+	if ip := clientIP(req, false); ip != "203.0.113.7" {
+		t.Logf("expected %s, got %s", "203.0.113.7", ip)
+		t.FailNow()
+	}
+
+	req.RemoteAddr = "198.51.100.1:54321"
+	if ip := clientIP(req, false); ip != "198.51.100.1" {
+		t.Logf("expected %s, got %s", "198.51.100.1", ip)
+		t.FailNow()
+	}
+	if ip := clientIP(req, true); ip != "203.0.113.7" {
+		t.Logf("expected %s, got %s", "203.0.113.7", ip)
+		t.FailNow()
+	}
+}
+
+func TestHTTP_AddServerRateLimited(t *testing.T) {
+	reg := NewRegistry(Config{
+		HealthcheckTimeout:   10 * time.Millisecond,
+		RateLimitInterval:    time.Hour,
+		RateLimitSourceBurst: 1,
+		RateLimitServerBurst: 1,
+	}).(*registry)
+	handler := reg.newHTTPHandler()
+
+	for i, expected := range []int{http.StatusBadRequest, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodPost, "/servers/add", strings.NewReader("127.0.0.1:1"))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != expected {
+			t.Logf("request %d: expected status %d, got %d", i, expected, rec.Code)
+			t.FailNow()
+		}
+	}
+}
+
+func TestHTTP_AddServerRecordingFails(t *testing.T) {
+	const mode = "RGM_TerroristHuntCoopMode"
+	beaconPort := startTestBeacon(t, "Unrecorded", mode)
+	reg := NewRegistry(Config{
+		HealthcheckTimeout: time.Second,
+		JournalPath:        filepath.Join(t.TempDir(), "missing", "journal"),
+	}).(*registry)
+
+	req := httptest.NewRequest(http.MethodPost, "/servers/add", strings.NewReader(fmt.Sprintf("127.0.0.1:%d", beaconPort-1000)))
+	rec := httptest.NewRecorder()
+	reg.newHTTPHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Logf("expected status %d, got %d: %s", http.StatusInternalServerError, rec.Code, rec.Body)
+		t.FailNow()
+	}
+	if n := reg.metrics.httpRegistrations.values["error"]; n != 1 {
+		t.Logf("expected 1 failed registration, got %d", n)
+		t.FailNow()
+	}
+}
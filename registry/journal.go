@@ -0,0 +1,166 @@
+package registry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// journal is an append-only file of Events, used to recover changes made
+// between checkpoints. Each Event is written as a single line of JSON and
+// synced to disk before Append returns.
+type journal struct {
+	path string
+	file *os.File
+	lock sync.Mutex
+
+	// When maxSize is positive, the file is moved to its first rotated path
+	// once it reaches maxSize bytes, replacing any previous rotated file.
+	maxSize int64
+}
+
+func newJournal(path string) *journal {
+	return &journal{path: path}
+}
+
+// Append writes the given Event to the end of the journal.
+func (j *journal) Append(e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.file == nil {
+		f, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		j.file = f
+	}
+
+	if _, err := j.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+
+	if j.maxSize > 0 {
+		if info, err := j.file.Stat(); err == nil && info.Size() >= j.maxSize {
+			j.file.Close()
+			j.file = nil
+			return os.Rename(j.path, rotatedPath(j.path, 1))
+		}
+	}
+	return nil
+}
+
+// Scan calls fn for every Event in the journal's rotated file, if any, and then
+// in the journal itself, in the order they were written. The files are read
+// line by line without blocking Appends, so lines which cannot be decoded,
+// including one which is still being written, are skipped.
+func (j *journal) Scan(fn func(Event)) error {
+	for _, path := range []string{rotatedPath(j.path, 1), j.path} {
+		if err := scanEvents(path, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanEvents(path string, fn func(Event)) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		var e Event
+		if len(line) > 0 && json.Unmarshal(line, &e) == nil {
+			fn(e)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Read returns every Event in the journal, in the order they were written.
+// Lines which cannot be decoded, such as a final line which was only partially
+// written before a crash, are skipped and returned as LineErrors.
+func (j *journal) Read() ([]Event, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	b, err := os.ReadFile(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		events  []Event
+		errs    LineErrors
+		scanner = bufio.NewScanner(bytes.NewReader(b))
+		line    = 0
+	)
+	scanner.Buffer(nil, len(b)+1)
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			errs = append(errs, LineError{Line: line, Err: err})
+			continue
+		}
+		events = append(events, e)
+	}
+
+	if len(errs) > 0 {
+		return events, errs
+	}
+	return events, nil
+}
+
+// Compact calls save, and then empties the journal if save succeeded. Appends
+// are blocked until Compact returns, so any Event which is not reflected in the
+// data written by save remains in the journal.
+func (j *journal) Compact(save func() error) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if err := save(); err != nil {
+		return err
+	}
+
+	if j.file != nil {
+		if err := j.file.Truncate(0); err != nil {
+			return err
+		}
+		return j.file.Sync()
+	}
+	if err := os.Truncate(j.path, 0); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package registry
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournal_ReadSkipsDamagedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.csv.journal")
+	j := newJournal(path)
+	for _, e := range []Event{
+		{Type: EventServerAdded, ServerID: "127.0.0.1:6777", Server: GameServer{Name: "One"}},
+		{Type: EventServerRemoved, ServerID: "127.0.0.1:6777"},
+	} {
+		if err := j.Append(e); err != nil {
+			t.Log("failed to append event:", err)
+			t.FailNow()
+		}
+	}
+
+	// Simulate a crash partway through writing an event.
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.Write([]byte(`{"type":"added","serv`))
+	f.Close()
+
+	events, err := newJournal(path).Read()
+	var skipped LineErrors
+	if !errors.As(err, &skipped) || len(skipped) != 1 || skipped[0].Line != 3 {
+		t.Log("expected line 3 to be skipped, got:", err)
+		t.FailNow()
+	}
+	if len(events) != 2 || events[1].Type != EventServerRemoved {
+		t.Logf("unexpected events: %+v", events)
+		t.FailNow()
+	}
+}
+
+func TestJournal_ReplayAfterCrash(t *testing.T) {
+	beaconPort := startTestBeacon(t, "Journaled", "RGM_TerroristHuntCoopMode")
+	dir := t.TempDir()
+	config := Config{
+		CheckpointPath:              filepath.Join(dir, "checkpoint.csv"),
+		JournalPath:                 filepath.Join(dir, "checkpoint.csv.journal"),
+		HealthcheckTimeout:          time.Second,
+		HealthcheckHealthyThreshold: 1,
+	}
+
+	// Save an empty checkpoint, then register a server before the next one.
+	reg := NewRegistry(config)
+	if err := reg.Checkpoint(); err != nil {
+		t.Log("failed to write checkpoint:", err)
+		t.FailNow()
+	}
+	data := newTestReport("Journaled", beaconPort-1000, beaconPort, "RGM_TerroristHuntCoopMode")
+	if err := reg.AddServer("127.0.0.1", data); err != nil {
+		t.Log("failed to add server:", err)
+		t.FailNow()
+	}
+
+	// A new registry should recover the server from the journal.
+	recovered := NewRegistry(config)
+	if err := recovered.Restore(); err != nil {
+		t.Log("failed to restore checkpoint:", err)
+		t.FailNow()
+	}
+	n, err := recovered.ReplayJournal()
+	if err != nil || n != 1 {
+		t.Logf("expected 1 replayed event, got %d (%v)", n, err)
+		t.FailNow()
+	}
+	if recovered.ServerCount() != 1 {
+		t.Logf("incorrect server count; expected %d, got %d", 1, recovered.ServerCount())
+		t.FailNow()
+	}
+
+	// Checkpointing should compact the journal.
+	if err := recovered.Checkpoint(); err != nil {
+		t.Log("failed to write checkpoint:", err)
+		t.FailNow()
+	}
+	if info, err := os.Stat(config.JournalPath); err != nil || info.Size() != 0 {
+		t.Log("expected an empty journal after checkpoint")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,60 @@
+package registry
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// jsonContentType is the media type used for JSON server lists.
+const jsonContentType = "application/json"
+
+// jsonServerList is the top-level document returned by the JSON server list
+// endpoints. New fields may be added, but existing fields will not be renamed
+// or removed.
+type jsonServerList struct {
+	Servers []jsonServer `json:"servers"`
+}
+
+// jsonServer is a GameServer along with its unique server ID.
+type jsonServer struct {
+	ID string `json:"id"`
+	GameServer
+}
+
+// serializeJSON writes the given GameServerMap as JSON output, sorted by server
+// name and then by server ID.
+func serializeJSON(m GameServerMap) ([]byte, error) {
+	list := jsonServerList{Servers: make([]jsonServer, 0, len(m))}
+	for id, server := range m {
+		list.Servers = append(list.Servers, jsonServer{ID: id, GameServer: server})
+	}
+
+	sort.Slice(list.Servers, func(i, j int) bool {
+		a, b := list.Servers[i], list.Servers[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+
+	return json.Marshal(list)
+}
+
+// acceptsJSON returns true when the request's Accept header asks for JSON.
+func acceptsJSON(req *http.Request) bool {
+	for _, accept := range req.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(mediaRange)
+			if err != nil {
+				continue
+			}
+			if mediaType == jsonContentType {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSerializeJSON(t *testing.T) {
+	b, err := serializeJSON(GameServerMap{
+		"127.0.0.1:6777": GameServer{
+			Name:       "Tango, Down",
+			IP:         "127.0.0.1",
+			Port:       6777,
+			BeaconPort: 7777,
+			GameMode:   "coop",
+			Health:     GameServerHealthStatus{Healthy: true, PassedChecks: 3},
+			FirstSeen:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			LastSeen:   time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC),
+		},
+	})
+	if err != nil {
+		t.Log("failed to serialize json:", err)
+		t.FailNow()
+	}
+
+	expected := `{"servers":[{"id":"127.0.0.1:6777","name":"Tango, Down","ip":"127.0.0.1","port":6777,"beacon_port":7777,"mode":"coop","health":{"healthy":true,"expired":false,"passed_checks":3,"failed_checks":0,"failing_since":"0001-01-01T00:00:00Z","parse_failed":false},"first_seen":"2024-01-02T03:04:05Z","last_seen":"2024-02-03T04:05:06Z","expired_at":"0001-01-01T00:00:00Z","hidden":false,"pinned":false}]}`
+	if string(b) != expected {
+		t.Log("unexpected json output")
+		t.Logf("expected %s, got %s", expected, string(b))
+		t.FailNow()
+	}
+}
+
+func TestSerializeJSON_Empty(t *testing.T) {
+	b, err := serializeJSON(GameServerMap{})
+	if err != nil {
+		t.Log("failed to serialize json:", err)
+		t.FailNow()
+	}
+
+	expected := `{"servers":[]}`
+	if string(b) != expected {
+		t.Logf("expected %s, got %s", expected, string(b))
+		t.FailNow()
+	}
+}
+
+func TestHTTP_ServersJSON(t *testing.T) {
+	reg := NewRegistry(Config{}).(*registry)
+	reg.GameServerMap = GameServerMap{
+		"127.0.0.1:6777": GameServer{Name: "Healthy", Health: GameServerHealthStatus{Healthy: true}},
+		"127.0.0.1:7777": GameServer{Name: "Unhealthy"},
+		"127.0.0.1:8777": GameServer{Name: "Expired", Health: GameServerHealthStatus{Expired: true}},
+	}
+	handler := reg.newHTTPHandler()
+
+	for path, count := range map[string]int{
+		"/servers":       1,
+		"/servers/all":   2,
+		"/servers/debug": 3,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", "text/html, application/json;q=0.9")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if ct := rec.Header().Get("Content-Type"); ct != jsonContentType {
+			t.Logf("%s: expected content type %s, got %s", path, jsonContentType, ct)
+			t.FailNow()
+		}
+		if rec.Header().Get("Vary") != "Accept" {
+			t.Logf("%s: expected response to vary by Accept", path)
+			t.FailNow()
+		}
+
+		var list jsonServerList
+		if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+			t.Logf("%s: failed to parse json: %v", path, err)
+			t.FailNow()
+		}
+		if len(list.Servers) != count {
+			t.Logf("%s: expected %d servers, got %d", path, count, len(list.Servers))
+			t.FailNow()
+		}
+	}
+}
+
+func TestHTTP_ServersCSVByDefault(t *testing.T) {
+	reg := NewRegistry(Config{}).(*registry)
+	req := httptest.NewRequest(http.MethodGet, "/servers", nil)
+	rec := httptest.NewRecorder()
+	reg.newHTTPHandler().ServeHTTP(rec, req)
+
+	if rec.Body.String() != "name,ip,port,mode" {
+		t.Log("unexpected csv output:", rec.Body.String())
+		t.FailNow()
+	}
+	if rec.Header().Get("Vary") != "Accept" {
+		t.Log("expected response to vary by Accept")
+		t.FailNow()
+	}
+}
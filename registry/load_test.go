@@ -0,0 +1,73 @@
+package registry
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadServers_Lenient(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.csv")
+	data := "name,ip,port,mode\nGood,127.0.0.1,6777,adv\nBad,127.0.0.1,port,adv\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	reg := NewRegistry(Config{}).(*registry)
+	err := reg.LoadServers(path)
+
+	var skipped LineErrors
+	if !errors.As(err, &skipped) || len(skipped) != 1 {
+		t.Log("expected one skipped line, got:", err)
+		t.FailNow()
+	}
+	if reg.ServerCount() != 1 {
+		t.Logf("incorrect server count; expected %d, got %d", 1, reg.ServerCount())
+		t.FailNow()
+	}
+
+	events, _ := reg.audit.Query("", time.Time{}, time.Time{})
+	if len(events) != 1 || events[0].Type != EventServerAdded || events[0].Channel != ChannelSeed {
+		t.Logf("expected the loaded server to be audited, got %+v", events)
+		t.FailNow()
+	}
+}
+
+func TestLoadServers_Strict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.csv")
+	data := "name,ip,port,mode\nGood,127.0.0.1,6777,adv\nBad,127.0.0.1,port,adv\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	reg := NewRegistry(Config{StrictLoading: true})
+	if err := reg.LoadServers(path); err == nil {
+		t.Log("expected strict loading to fail")
+		t.FailNow()
+	}
+	if reg.ServerCount() != 0 {
+		t.Logf("incorrect server count; expected %d, got %d", 0, reg.ServerCount())
+		t.FailNow()
+	}
+}
+
+func TestLoadServers_NoValidLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.csv")
+	if err := os.WriteFile(path, []byte("garbage\nmore garbage\n"), 0644); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	reg := NewRegistry(Config{})
+	err := reg.LoadServers(path)
+
+	var skipped LineErrors
+	if err == nil || errors.As(err, &skipped) {
+		t.Log("expected a fatal error, got:", err)
+		t.FailNow()
+	}
+}
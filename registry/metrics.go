@@ -0,0 +1,205 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metricsContentType is the media type of the Prometheus text format.
+const metricsContentType = "text/plain; version=0.0.4"
+
+// durationBuckets are the upper bounds, in seconds, of the histogram buckets
+// used for healthcheck and checkpoint durations.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metrics holds the values exposed by /metrics. Server counts and UDP counters
+// are read when metrics are collected.
+type metrics struct {
+	healthcheckDuration *histogram
+	healthcheckFailures *counterVec // By reason.
+	beaconsRejected     *counterVec // By reason.
+	httpRegistrations   *counterVec // By outcome.
+	checkpointDuration  *histogram
+	checkpointFailures  atomic.Uint64
+	githubErrors        atomic.Uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		healthcheckDuration: newHistogram(durationBuckets),
+		healthcheckFailures: newCounterVec(),
+		beaconsRejected:     newCounterVec(),
+		httpRegistrations:   newCounterVec(),
+		checkpointDuration:  newHistogram(durationBuckets),
+	}
+}
+
+// observeHealthcheck records the result of a beacon request. Requests which
+// succeed with a report which can't be parsed are counted as failures, with the
+// reason "parse".
+func (m *metrics) observeHealthcheck(p probeResult, parseFailed bool) {
+	m.healthcheckDuration.Observe(p.latency.Seconds())
+
+	var netErr net.Error
+	switch {
+	case p.err == nil && parseFailed:
+		m.healthcheckFailures.Inc("parse")
+	case p.err == nil:
+	case errors.As(p.err, &netErr) && netErr.Timeout():
+		m.healthcheckFailures.Inc("timeout")
+	default:
+		m.healthcheckFailures.Inc("unreachable")
+	}
+}
+
+// observeCheckpoint records the duration and result of writing a checkpoint
+// which started at the given time.
+func (m *metrics) observeCheckpoint(start time.Time, err error) {
+	m.checkpointDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.checkpointFailures.Add(1)
+	}
+}
+
+// serveMetrics handles requests to the metrics endpoint.
+func (r *registry) serveMetrics(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", metricsContentType)
+	r.writeMetrics(w)
+}
+
+// writeMetrics writes every metric in the Prometheus text format.
+func (r *registry) writeMetrics(w io.Writer) {
+	servers := r.servers()
+	var healthy, expired int
+	for _, s := range servers {
+		if s.Health.Healthy {
+			healthy++
+		}
+		if s.Health.Expired {
+			expired++
+		}
+	}
+	writeGauge(w, "openrvs_servers", "Number of known servers.", len(servers))
+	writeGauge(w, "openrvs_servers_healthy", "Number of healthy servers.", healthy)
+	writeGauge(w, "openrvs_servers_hidden", "Number of servers left out of /servers.", len(servers)-len(filterHealthyServers(servers)))
+	writeGauge(w, "openrvs_servers_expired", "Number of expired servers.", expired)
+
+	writeHistogram(w, "openrvs_healthcheck_duration_seconds", "Time taken by each healthcheck.", r.metrics.healthcheckDuration)
+	writeCounterVec(w, "openrvs_healthcheck_failures_total", "Failed healthchecks, by reason.", "reason", r.metrics.healthcheckFailures.Values())
+
+	stats := r.UDPStats()
+	writeCounter(w, "openrvs_udp_beacons_received_total", "UDP beacons received.", stats.Received)
+	writeCounter(w, "openrvs_udp_beacons_handled_total", "UDP beacons processed by a worker.", stats.Handled)
+	writeGauge(w, "openrvs_udp_queue_depth", "UDP beacons waiting for a worker.", int(stats.QueueDepth))
+	rejected := r.metrics.beaconsRejected.Values()
+	rejected["queue_full"] += stats.Dropped
+	rejected["source_rate_limited"] += stats.Limited
+	writeCounterVec(w, "openrvs_udp_beacons_rejected_total", "UDP beacons which did not register a server, by reason.", "reason", rejected)
+
+	writeCounterVec(w, "openrvs_http_registrations_total", "Requests to /servers/add, by outcome.", "outcome", r.metrics.httpRegistrations.Values())
+
+	writeHistogram(w, "openrvs_checkpoint_duration_seconds", "Time taken to save each checkpoint.", r.metrics.checkpointDuration)
+	writeCounter(w, "openrvs_checkpoint_failures_total", "Checkpoints which failed to save.", r.metrics.checkpointFailures.Load())
+
+	writeCounter(w, "openrvs_github_errors_total", "Failed lookups of the latest OpenRVS version.", r.metrics.githubErrors.Load())
+}
+
+func writeGauge(w io.Writer, name, help string, value int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, value)
+}
+
+func writeCounter(w io.Writer, name, help string, value uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+}
+
+func writeCounterVec(w io.Writer, name, help, label string, values map[string]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", name, label, k, values[k])
+	}
+}
+
+func writeHistogram(w io.Writer, name, help string, h *histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	counts, sum, count := h.Snapshot()
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, bound, cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, count)
+	fmt.Fprintf(w, "%s_sum %g\n%s_count %d\n", name, sum, name, count)
+}
+
+// counterVec is a set of counters identified by the value of a single label.
+type counterVec struct {
+	lock   sync.Mutex
+	values map[string]uint64
+}
+
+func newCounterVec() *counterVec {
+	return &counterVec{values: make(map[string]uint64)}
+}
+
+// Inc increments the counter with the given label value.
+func (c *counterVec) Inc(label string) {
+	c.lock.Lock()
+	c.values[label]++
+	c.lock.Unlock()
+}
+
+// Values returns a copy of every counter.
+func (c *counterVec) Values() map[string]uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	values := make(map[string]uint64, len(c.values))
+	for k, v := range c.values {
+		values[k] = v
+	}
+	return values
+}
+
+// histogram counts observations in buckets with the given upper bounds.
+type histogram struct {
+	bounds []float64
+	lock   sync.Mutex
+	counts []uint64 // Not cumulative. The last count is for +Inf.
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+// Observe adds a single observation.
+func (h *histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v) // First bound >= v.
+
+	h.lock.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+	h.lock.Unlock()
+}
+
+// Snapshot returns a copy of the bucket counts, along with the sum and count of
+// every observation.
+func (h *histogram) Snapshot() ([]uint64, float64, uint64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return append([]uint64(nil), h.counts...), h.sum, h.count
+}
@@ -0,0 +1,62 @@
+package registry
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]float64{0.1, 1})
+	for _, v := range []float64{0.05, 0.1, 0.5, 5} {
+		h.Observe(v)
+	}
+
+	var b bytes.Buffer
+	writeHistogram(&b, "test_seconds", "Test.", h)
+	expected := `# HELP test_seconds Test.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 2
+test_seconds_bucket{le="1"} 3
+test_seconds_bucket{le="+Inf"} 4
+test_seconds_sum 5.65
+test_seconds_count 4
+`
+	if b.String() != expected {
+		t.Logf("expected:\n%s\ngot:\n%s", expected, b.String())
+		t.FailNow()
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	reg := NewRegistry(Config{HealthcheckTimeout: 10 * time.Millisecond}).(*registry)
+	reg.GameServerMap["127.0.0.1:1"] = GameServer{Name: "Down", IP: "127.0.0.1", Port: 1, BeaconPort: 1}
+	reg.GameServerMap["127.0.0.1:2"] = GameServer{Name: "Up", IP: "127.0.0.1", Port: 2, Health: GameServerHealthStatus{Healthy: true}}
+	reg.SendHealthchecks(func(GameServer) {}, func(GameServer) {})
+	reg.AddServer("127.0.0.1", []byte("not a beacon"))
+
+	handler := reg.newHTTPHandler()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/servers/add", nil))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, expected := range []string{
+		"openrvs_servers 2\n",
+		"openrvs_servers_hidden 1\n",
+		"openrvs_healthcheck_duration_seconds_count 2\n",
+		`openrvs_healthcheck_failures_total{reason="unreachable"} 2`,
+		`openrvs_udp_beacons_rejected_total{reason="invalid"} 1`,
+		"openrvs_udp_beacons_handled_total 0\n",
+		"openrvs_udp_queue_depth 0\n",
+		`openrvs_http_registrations_total{outcome="bad_request"} 1`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Logf("expected %q in metrics, got:\n%s", expected, body)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package registry
+
+import "time"
+
+// pruneExpiredServers removes servers which have been expired for longer than
+// Config.ExpiredRetention, archiving them to the graveyard file if configured.
+// Expired servers are kept forever when ExpiredRetention is zero.
+func (r *registry) pruneExpiredServers() {
+	if r.config().ExpiredRetention <= 0 {
+		return
+	}
+
+	var events []Event
+	r.GameServerMapLock.Lock()
+	for id, server := range r.GameServerMap {
+		if isPrunable(server, r.config().ExpiredRetention) {
+			delete(r.GameServerMap, id)
+			server := server // Each Event keeps a pointer to its own copy.
+			events = append(events, r.sequence(newEvent(EventServerPruned, id, &server, server, origin{channel: ChannelHealthcheck})))
+		}
+	}
+	r.GameServerMapLock.Unlock()
+
+	for _, e := range events {
+		// Failed writes to the graveyard are not retried, since the server
+		// is no longer needed by the registry.
+		if r.graveyard != nil {
+			err := r.graveyard.Append(Event{
+				Time:     time.Now(),
+				Type:     EventServerPruned,
+				ServerID: e.ServerID,
+				Server:   e.Server,
+			})
+			if err != nil {
+				r.logger.Warn("failed to archive server", "server_id", e.ServerID, "error", err)
+			}
+		}
+		r.emit(e)
+	}
+}
+
+// isPrunable returns true when the given server has been expired for at least
+// the given retention period. Pinned servers are never prunable.
+func isPrunable(s GameServer, retention time.Duration) bool {
+	return !s.Pinned && s.Health.Expired && !s.ExpiredAt.IsZero() && time.Since(s.ExpiredAt) >= retention
+}
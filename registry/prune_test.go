@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPruneExpiredServers(t *testing.T) {
+	dir := t.TempDir()
+	config := Config{
+		JournalPath:      filepath.Join(dir, "checkpoint.csv.journal"),
+		GraveyardPath:    filepath.Join(dir, "graveyard.jsonl"),
+		ExpiredRetention: time.Hour,
+	}
+	reg := NewRegistry(config).(*registry)
+	reg.GameServerMap = GameServerMap{
+		"127.0.0.1:6777": GameServer{Name: "Healthy", Health: GameServerHealthStatus{Healthy: true}},
+		"127.0.0.1:7777": GameServer{
+			Name:      "Recently Expired",
+			Health:    GameServerHealthStatus{Expired: true},
+			ExpiredAt: time.Now().Add(-time.Minute),
+		},
+		"127.0.0.1:8777": GameServer{
+			Name:      "Long Expired",
+			Health:    GameServerHealthStatus{Expired: true},
+			ExpiredAt: time.Now().Add(-2 * time.Hour),
+		},
+	}
+
+	reg.pruneExpiredServers()
+
+	if reg.ServerCount() != 2 {
+		t.Logf("incorrect server count; expected %d, got %d", 2, reg.ServerCount())
+		t.FailNow()
+	}
+	if _, ok := reg.GameServerMap["127.0.0.1:8777"]; ok {
+		t.Log("expected long expired server to be pruned")
+		t.FailNow()
+	}
+
+	// The pruned server should be archived and journaled.
+	for _, path := range []string{config.GraveyardPath, config.JournalPath} {
+		events, err := newJournal(path).Read()
+		if err != nil || len(events) != 1 {
+			t.Logf("%s: expected 1 event, got %d (%v)", path, len(events), err)
+			t.FailNow()
+		}
+		if events[0].Type != EventServerPruned || events[0].Server.Name != "Long Expired" {
+			t.Logf("%s: unexpected event %+v", path, events[0])
+			t.FailNow()
+		}
+	}
+}
+
+func TestPruneExpiredServers_Disabled(t *testing.T) {
+	reg := NewRegistry(Config{}).(*registry)
+	reg.GameServerMap = GameServerMap{
+		"127.0.0.1:8777": GameServer{
+			Name:      "Long Expired",
+			Health:    GameServerHealthStatus{Expired: true},
+			ExpiredAt: time.Now().Add(-48 * time.Hour),
+		},
+	}
+
+	reg.pruneExpiredServers()
+
+	if reg.ServerCount() != 1 {
+		t.Log("expired servers should be kept when retention is zero")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,89 @@
+package registry
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiterSweepInterval is how often idle buckets are removed.
+const rateLimiterSweepInterval = time.Minute
+
+// rateLimiter is a token bucket rate limiter with a separate bucket for each
+// key. Each bucket holds up to burst tokens, and gains one token per interval.
+// A nil rateLimiter allows everything.
+type rateLimiter struct {
+	interval time.Duration
+	burst    float64
+
+	lock      sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// newRateLimiter initializes and returns a rateLimiter, or nil when interval or
+// burst is zero.
+func newRateLimiter(interval time.Duration, burst int) *rateLimiter {
+	if interval <= 0 || burst <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		interval:  interval,
+		burst:     float64(burst),
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket for the given key, and returns false if
+// the bucket is empty.
+func (l *rateLimiter) Allow(key string) bool {
+	if l == nil {
+		return true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= rateLimiterSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, updated: now}
+		l.buckets[key] = b
+	}
+
+	l.refill(b, now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refill adds the tokens gained since the bucket was last updated.
+func (l *rateLimiter) refill(b *tokenBucket, now time.Time) {
+	b.tokens += float64(now.Sub(b.updated)) / float64(l.interval)
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.updated = now
+}
+
+// sweep removes full buckets, which behave the same as missing buckets.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,100 @@
+package registry
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(50*time.Millisecond, 2)
+
+	for i, expected := range []bool{true, true, false} {
+		if l.Allow("1.2.3.4") != expected {
+			t.Logf("request %d: expected %v", i, expected)
+			t.FailNow()
+		}
+	}
+
+	// Other keys have their own buckets.
+	if !l.Allow("5.6.7.8") {
+		t.Log("expected a separate bucket for each key")
+		t.FailNow()
+	}
+
+	// Tokens are added back over time.
+	time.Sleep(60 * time.Millisecond)
+	if !l.Allow("1.2.3.4") {
+		t.Log("expected a token to be added after one interval")
+		t.FailNow()
+	}
+	if l.Allow("1.2.3.4") {
+		t.Log("expected only one token to be added after one interval")
+		t.FailNow()
+	}
+}
+
+func TestRateLimiter_Disabled(t *testing.T) {
+	l := newRateLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		if !l.Allow("1.2.3.4") {
+			t.Log("expected a disabled rate limiter to allow everything")
+			t.FailNow()
+		}
+	}
+}
+
+func TestAddServer_RateLimited(t *testing.T) {
+	beaconPort := startTestBeacon(t, "Limited", "RGM_TerroristHuntCoopMode")
+	reg := NewRegistry(Config{
+		HealthcheckTimeout:   time.Second,
+		RateLimitInterval:    time.Hour,
+		RateLimitServerBurst: 2,
+	})
+
+	data := newTestReport("Limited", beaconPort-1000, beaconPort, "RGM_TerroristHuntCoopMode")
+	for i, expected := range []error{nil, nil, errRateLimited} {
+		if err := reg.AddServer("127.0.0.1", data); err != expected {
+			t.Logf("registration %d: expected %v, got %v", i, expected, err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestAddServer_Dedup(t *testing.T) {
+	beaconPort := startTestBeacon(t, "Dedup", "RGM_TerroristHuntCoopMode")
+	reg := NewRegistry(Config{
+		HealthcheckTimeout:            100 * time.Millisecond,
+		HealthcheckHealthyThreshold:   1,
+		HealthcheckUnhealthyThreshold: 1,
+		RegistrationDedupWindow:       time.Minute,
+	}).(*registry)
+
+	port := beaconPort - 1000
+	if err := reg.AddServer("127.0.0.1", newTestReport("Dedup", port, beaconPort, "RGM_TerroristHuntCoopMode")); err != nil {
+		t.Log("failed to add server:", err)
+		t.FailNow()
+	}
+	serverID := fmt.Sprintf("127.0.0.1:%d", port)
+	first := reg.GameServerMap[serverID]
+	if !first.Health.Healthy {
+		t.Log("expected server to be healthy after registration")
+		t.FailNow()
+	}
+
+	// Report a beacon port with nothing listening. If the server were
+	// healthchecked again, it would become unhealthy.
+	if err := reg.AddServer("127.0.0.1", newTestReport("Dedup", port, 1, "RGM_TerroristHuntCoopMode")); err != nil {
+		t.Log("failed to add server:", err)
+		t.FailNow()
+	}
+	second := reg.GameServerMap[serverID]
+	if !second.Health.Healthy || second.BeaconPort != beaconPort {
+		t.Log("expected repeated registration to skip the healthcheck")
+		t.FailNow()
+	}
+	if !second.LastSeen.After(first.LastSeen) {
+		t.Log("expected repeated registration to update LastSeen")
+		t.FailNow()
+	}
+}
@@ -2,135 +2,555 @@
 package registry
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net"
-	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	beacon "github.com/willroberts/openrvs-beacon"
 	"github.com/willroberts/openrvs-registry/ravenshield"
 )
 
+// errRateLimited is returned when registrations arrive too frequently.
+var errRateLimited = errors.New("registration rate limit exceeded")
+
 // Registry maintains a list of servers, with functionality for healthchecking
-// and loading/saving servers in CSV format.
+// and loading/saving servers in CSV format or in the configured Store.
 type Registry interface {
 	LoadServers(csvFile string) error
 	SaveServers(csvFile string) error
+	Restore() error
+	ReplayJournal() (int, error)
+	Checkpoint() error
+	LoadHistory() error
+	MergeServers(csvFile string) (int, error)
+	LoadBans() error
+	Reload() error
 	AddServer(ip string, data []byte) error
 	ServerCount() int
 	SendHealthchecks(onHealthy func(GameServer), onUnhealthy func(GameServer))
 
+	Run(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+
 	HandleHTTP(listenAddress string) error
-	HandleUDP(port int, h UDPHandler, stopCh chan struct{}) error
+	HandleUDP(listenAddress string, h UDPHandler, stopCh chan struct{}) error
+	UDPStats() UDPStats
 }
 
 type registry struct {
-	Config            Config
+	Config            Config // Use config() to read, since it changes on Reload.
+	configLock        sync.RWMutex
+	configChanged     chan struct{} // Closed and replaced when Config changes.
 	CSV               CSVSerializer
+	Store             Store
 	GameServerMap     GameServerMap
 	GameServerMapLock sync.RWMutex
+
+	journal   *journal
+	graveyard *journal
+	sequencer *eventSequencer
+	bans      *banList
+	audit     *auditLog
+	history   *history
+	metrics   *metrics
+	udpStats  udpCounters
+	logger    *slog.Logger
+
+	// Registrations are rate limited by source IP and by server ID.
+	sourceLimiter *rateLimiter
+	serverLimiter *rateLimiter
+
+	running  atomic.Bool
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
 }
 
-// NewRegistry initializes and returns a Registry.
+// NewRegistry initializes and returns a Registry. Unless Config.Store is set,
+// servers are stored in a CSV file at Config.CheckpointPath.
 func NewRegistry(config Config) Registry {
+	csv := NewCSVSerializer()
+	csv.EnableLenient(!config.StrictLoading)
+
+	store := config.Store
+	if store == nil {
+		store = NewCSVStore(config.CheckpointPath, config.CheckpointRotations, csv)
+	}
+
+	var j, graveyard *journal
+	if config.JournalPath != "" {
+		j = newJournal(config.JournalPath)
+	}
+	if config.GraveyardPath != "" {
+		graveyard = newJournal(config.GraveyardPath)
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	return &registry{
 		Config:        config,
-		CSV:           NewCSVSerializer(),
+		CSV:           csv,
+		Store:         store,
 		GameServerMap: make(GameServerMap),
+		journal:       j,
+		graveyard:     graveyard,
+		sequencer:     newEventSequencer(),
+		bans:          newBanList(config.BanListPath),
+		audit:         newAuditLog(config.AuditLogSize, config.AuditLogPath, config.AuditLogMaxBytes),
+		history:       newHistory(config.HistoryPath),
+		metrics:       newMetrics(),
+		logger:        logger,
+		sourceLimiter: newRateLimiter(config.RateLimitInterval, config.RateLimitSourceBurst),
+		serverLimiter: newRateLimiter(config.RateLimitInterval, config.RateLimitServerBurst),
+		configChanged: make(chan struct{}),
+		stopCh:        make(chan struct{}),
+		doneCh:        make(chan struct{}),
 	}
 }
 
+// LoadServers replaces the current server list with the servers in csvFile.
+// When csvFile is the configured checkpoint path and cannot be loaded, each of
+// its rotated previous versions is tried in turn, newest first. Unless
+// Config.StrictLoading is set, malformed lines are skipped and returned as
+// LineErrors after the remaining servers have been loaded. Any other error
+// means that no servers were loaded. Each change is recorded in the audit log.
 func (r *registry) LoadServers(csvFile string) error {
-	b, err := os.ReadFile(csvFile)
-	if err != nil {
+	o := origin{channel: ChannelSeed}
+	rotations := 0
+	if csvFile == r.config().CheckpointPath {
+		o.channel = ChannelStore
+		rotations = r.config().CheckpointRotations
+	}
+	servers, err := NewCSVStore(csvFile, rotations, r.CSV).Load()
+	if servers == nil {
 		return err
 	}
 
-	parsed, err := r.CSV.Deserialize(b)
-	if err != nil {
+	r.GameServerMapLock.Lock()
+	previous := r.GameServerMap
+	r.GameServerMap = servers
+	r.GameServerMapLock.Unlock()
+
+	for id, server := range servers {
+		var before *GameServer
+		if s, ok := previous[id]; ok {
+			before = &s
+		}
+		r.emitLoaded(newEvent(EventServerAdded, id, before, server, o))
+	}
+	for id, server := range previous {
+		if _, ok := servers[id]; !ok {
+			before := server
+			r.emitLoaded(newEvent(EventServerRemoved, id, &before, GameServer{}, o))
+		}
+	}
+
+	return err
+}
+
+// SaveServers writes the current server list to csvFile in checkpoint format.
+// Previous versions are only rotated when csvFile is the configured checkpoint
+// path, so ad-hoc exports to other paths don't leave extra files behind.
+func (r *registry) SaveServers(csvFile string) error {
+	rotations := 0
+	if csvFile == r.config().CheckpointPath {
+		rotations = r.config().CheckpointRotations
+	}
+	start := time.Now()
+	err := NewCSVStore(csvFile, rotations, r.CSV).Save(r.servers())
+	r.metrics.observeCheckpoint(start, err)
+	return err
+}
+
+// Restore adds servers from the configured Store to the current server list.
+// Servers which are already known are not modified, so Restore can be called
+// repeatedly to pick up servers registered by other registries sharing the same
+// Store. Errors are handled in the same way as LoadServers. Added servers are
+// recorded in the audit log.
+func (r *registry) Restore() error {
+	servers, err := r.Store.Load()
+	if servers == nil {
 		return err
 	}
+
+	added := make(GameServerMap)
 	r.GameServerMapLock.Lock()
-	r.GameServerMap = parsed
+	for id, server := range servers {
+		if _, ok := r.GameServerMap[id]; !ok {
+			r.GameServerMap[id] = server
+			added[id] = server
+		}
+	}
 	r.GameServerMapLock.Unlock()
 
-	return nil
+	for id, server := range added {
+		r.emitLoaded(newEvent(EventServerAdded, id, nil, server, origin{channel: ChannelStore}))
+	}
+
+	return err
 }
 
-func (r *registry) SaveServers(csvFile string) error {
-	data := r.CSV.Serialize(r.GameServerMap)
-	return os.WriteFile(r.Config.CheckpointPath, data, 0644)
+// MergeServers adds the servers in csvFile which are not already in the server
+// list, and returns the number of servers added. Known servers, including their
+// health, are not modified, and banned servers are skipped. Errors are handled
+// in the same way as LoadServers.
+func (r *registry) MergeServers(csvFile string) (int, error) {
+	servers, err := NewCSVStore(csvFile, 0, r.CSV).Load()
+	if servers == nil {
+		return 0, err
+	}
+
+	var events []Event
+	r.GameServerMapLock.Lock()
+	for id, server := range servers {
+		if _, ok := r.GameServerMap[id]; ok {
+			continue
+		}
+		if _, banned := r.bans.Match(server.IP, server.Port, server.Name); banned {
+			continue
+		}
+		r.GameServerMap[id] = server
+		events = append(events, r.sequence(newEvent(EventServerAdded, id, nil, server, origin{channel: ChannelSeed})))
+	}
+	r.GameServerMapLock.Unlock()
+
+	for _, e := range events {
+		r.emit(e)
+	}
+
+	return len(events), err
+}
+
+// ReplayJournal applies every change recorded in the journal since the last
+// checkpoint to the current server list, and returns the number of changes
+// applied. It should be called once on startup, after loading servers. Damaged
+// journal lines are skipped and returned as LineErrors.
+func (r *registry) ReplayJournal() (int, error) {
+	if r.journal == nil {
+		return 0, nil
+	}
+
+	events, err := r.journal.Read()
+	r.GameServerMapLock.Lock()
+	for _, e := range events {
+		applyEvent(r.GameServerMap, e)
+	}
+	r.GameServerMapLock.Unlock()
+
+	return len(events), err
 }
 
+// Checkpoint saves the current server list to the configured Store, and then
+// empties the journal. Healthcheck history is also saved when
+// Config.HistoryPath is set.
+func (r *registry) Checkpoint() error {
+	start := time.Now()
+	historyErr := r.history.Save()
+
+	var err error
+	if r.journal == nil {
+		err = r.Store.Save(r.servers())
+	} else {
+		err = r.journal.Compact(func() error {
+			return r.Store.Save(r.servers())
+		})
+	}
+
+	err = errors.Join(err, historyErr)
+	r.metrics.observeCheckpoint(start, err)
+	return err
+}
+
+// LoadHistory replaces the current healthcheck history with the history saved
+// at Config.HistoryPath by the last checkpoint.
+func (r *registry) LoadHistory() error {
+	return r.history.Load()
+}
+
+// LoadBans replaces the current ban list with the one saved at
+// Config.BanListPath, and removes any servers which are now banned. Invalid bans
+// are skipped and returned as an error after the remaining bans are loaded.
+func (r *registry) LoadBans() error {
+	err := r.bans.Load()
+	r.removeBannedServers(origin{channel: ChannelAdmin})
+	return err
+}
+
+// AddServer registers the server which sent the given REPORT beacon data. If
+// the server is already known, healthy and was seen within the configured
+// dedup window, only its LastSeen time is updated. Otherwise the server is
+// healthchecked and verified before being added, subject to the ban list and
+// the per-server rate limit. Since the source port of the beacon is not known,
+// it is not checked when Config.RegistrationVerification is VerifySource.
 func (r *registry) AddServer(ip string, data []byte) error {
+	return r.addServer(&net.UDPAddr{IP: net.ParseIP(ip)}, data)
+}
+
+// addServer registers the server which sent the given REPORT beacon data from
+// the given source address, as described by AddServer.
+func (r *registry) addServer(source *net.UDPAddr, data []byte) error {
+	report, err := parseRegistration(source.IP.String(), data)
+	if err != nil {
+		r.metrics.beaconsRejected.Inc("invalid")
+		return err
+	}
+	if err := r.checkBans(report.IPAddress, report.Port, report.ServerName); err != nil {
+		r.metrics.beaconsRejected.Inc("banned")
+		return err
+	}
+
+	serverID := fmt.Sprintf("%s:%d", report.IPAddress, report.Port)
+	if r.touchServer(serverID) {
+		return nil
+	}
+	if !r.serverLimiter.Allow(serverID) {
+		r.metrics.beaconsRejected.Inc("rate_limited")
+		return errRateLimited
+	}
+
+	err = r.registerServer(report, probeResult{}, source, origin{channel: ChannelUDP, sourceIP: source.IP.String()})
+	if errors.Is(err, errVerificationFailed) {
+		r.metrics.beaconsRejected.Inc("unverified")
+	}
+	return err
+}
+
+// parseRegistration parses and validates REPORT beacon data from a server
+// which is registering itself.
+func parseRegistration(ip string, data []byte) (*beacon.ServerReport, error) {
 	if net.ParseIP(ip).IsPrivate() {
-		return errors.New("skipping server with private IP")
+		return nil, errors.New("skipping server with private IP")
 	}
 
 	report, err := beacon.ParseServerReport(ip, data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if report.ServerName == "" {
-		return errors.New("skipping server with no name")
+		return nil, errors.New("skipping server with no name")
 	}
 
 	if report.Port == 0 {
-		return errors.New("skipping server with no port")
+		return nil, errors.New("skipping server with no port")
 	}
 
 	if report.CurrentMode == "" {
-		return errors.New("skipping server with no game mode")
+		return nil, errors.New("skipping server with no game mode")
 	}
 
-	// Manually healthcheck this server before adding it to the map.
+	return report, nil
+}
+
+// touchServer updates LastSeen and returns true if the given server is known,
+// healthy and was seen within Config.RegistrationDedupWindow.
+func (r *registry) touchServer(serverID string) bool {
+	if r.config().RegistrationDedupWindow <= 0 {
+		return false
+	}
+
+	r.GameServerMapLock.Lock()
+	defer r.GameServerMapLock.Unlock()
+
+	server, ok := r.GameServerMap[serverID]
+	if !ok || !server.Health.Healthy || time.Since(server.LastSeen) >= r.config().RegistrationDedupWindow {
+		return false
+	}
+	server.LastSeen = time.Now()
+	r.GameServerMap[serverID] = server
+	return true
+}
+
+// registerServer healthchecks the server in the given report and adds it to the
+// server list. When source is nil, p must be the probe which returned the
+// report, and is used as the healthcheck result. Otherwise the report was
+// received from source, and the server is probed and verified according to
+// Config.RegistrationVerification. The origin is recorded in the audit log.
+func (r *registry) registerServer(report *beacon.ServerReport, p probeResult, source *net.UDPAddr, o origin) error {
 	serverID := fmt.Sprintf("%s:%d", report.IPAddress, report.Port)
-	server := r.updateServerHealth(GameServer{
+	s := GameServer{
 		Name:       report.ServerName,
 		IP:         report.IPAddress,
 		Port:       report.Port,
 		BeaconPort: report.BeaconPort,
 		GameMode:   ravenshield.GameModes[report.CurrentMode],
-	}, func(GameServer) {}, func(GameServer) {})
+		FirstSeen:  time.Now(),
+	}
+	if s.BeaconPort == 0 {
+		s.BeaconPort = s.Port + 1000
+	}
+
+	// Manually healthcheck this server before adding it to the map.
+	if source != nil {
+		p = r.probe(s.IP, s.BeaconPort)
+		if err := r.verifyRegistration(report, source, p.data, p.err); err != nil {
+			return err
+		}
+	}
+	server := r.applyHealthcheck(s, p, func(GameServer) {}, func(GameServer) {})
 
+	// Preserve the original registration time and any changes made by admins
+	// for known servers. The entry is read under the same lock as the write so
+	// that changes made while the server was being probed are not lost.
+	var before *GameServer
 	r.GameServerMapLock.Lock()
+	if existing, ok := r.GameServerMap[serverID]; ok {
+		before = &existing
+		if !existing.FirstSeen.IsZero() {
+			server.FirstSeen = existing.FirstSeen
+		}
+		server.Hidden = existing.Hidden
+		server.Pinned = existing.Pinned
+		if existing.Pinned {
+			server.Name = existing.Name
+			server.GameMode = existing.GameMode
+		}
+	}
 	r.GameServerMap[serverID] = server
+	e := r.sequence(newEvent(EventServerAdded, serverID, before, server, o))
 	r.GameServerMapLock.Unlock()
 
+	if err := r.emit(e); err != nil {
+		return fmt.Errorf("failed to record new server: %w", err)
+	}
+
 	return nil
 }
 
 func (r *registry) ServerCount() int {
+	r.GameServerMapLock.RLock()
+	defer r.GameServerMapLock.RUnlock()
 	return len(r.GameServerMap)
 }
 
+// servers returns a copy of the current server list which is safe to use
+// without holding GameServerMapLock.
+func (r *registry) servers() GameServerMap {
+	r.GameServerMapLock.RLock()
+	defer r.GameServerMapLock.RUnlock()
+
+	servers := make(GameServerMap, len(r.GameServerMap))
+	for id, server := range r.GameServerMap {
+		servers[id] = server
+	}
+	return servers
+}
+
 func (r *registry) SendHealthchecks(
 	onHealthy func(s GameServer),
 	onUnhealthy func(s GameServer),
 ) {
+	// Banned servers are removed without being healthchecked.
+	o := origin{channel: ChannelHealthcheck}
+	r.removeBannedServers(o)
+
 	var (
 		output = make(GameServerMap)
 		wg     sync.WaitGroup
 		lock   sync.RWMutex
+		slots  = make(chan struct{}, r.healthcheckConcurrency())
 	)
 
-	for hostport, server := range r.GameServerMap {
+	for hostport, server := range r.servers() {
 		wg.Add(1)
+		slots <- struct{}{}
 		go func(hostport string, server GameServer) {
 			s := r.updateServerHealth(server, onHealthy, onUnhealthy)
 			lock.Lock()
 			output[hostport] = s
 			lock.Unlock()
+			<-slots
 			wg.Done()
 		}(hostport, server)
 	}
 	wg.Wait()
 
+	r.mergeHealthchecks(output, o)
+
+	// Remove servers which were renamed to a banned name.
+	r.removeBannedServers(o)
+	r.pruneExpiredServers()
+}
+
+// mergeHealthchecks updates the server list with the given healthchecked
+// servers, and records any changes. Only servers which are still present are
+// updated, so any servers which were removed or added while healthchecks were
+// in progress are left as they are. Only the results of the healthchecks are
+// merged into the current servers, so changes made by admins while healthchecks
+// were in progress are kept. The merged servers are returned.
+func (r *registry) mergeHealthchecks(output GameServerMap, o origin) GameServerMap {
+	var events []Event
+	merged := make(GameServerMap, len(output))
 	r.GameServerMapLock.Lock()
-	r.GameServerMap = output
+	for hostport, checked := range output {
+		if previous, ok := r.GameServerMap[hostport]; ok {
+			server := mergeHealthcheck(previous, checked)
+			r.GameServerMap[hostport] = server
+			merged[hostport] = server
+			for _, eventType := range healthEvents(previous, server) {
+				events = append(events, r.sequence(newEvent(eventType, hostport, &previous, server, o)))
+			}
+		}
+	}
 	r.GameServerMapLock.Unlock()
+
+	// Record servers whose visible state has changed. Check counters alone are
+	// not recorded until the next checkpoint, which also covers any changes
+	// which failed to be recorded here.
+	for _, e := range events {
+		r.emit(e)
+	}
+	return merged
+}
+
+// mergeHealthcheck returns the current server updated with the fields set by a
+// healthcheck of an earlier copy of it. The name and game mode are only taken
+// from a successful healthcheck, and never for pinned servers.
+func mergeHealthcheck(current, checked GameServer) GameServer {
+	current.Health = checked.Health
+	if checked.LastSeen.After(current.LastSeen) {
+		current.LastSeen = checked.LastSeen // Beacons may have been seen since.
+	}
+	current.ExpiredAt = checked.ExpiredAt
+	if current.BeaconPort == 0 {
+		current.BeaconPort = checked.BeaconPort
+	}
+	if !current.Pinned && checked.Health.PassedChecks > 0 && !checked.Health.ParseFailed {
+		current.Name = checked.Name
+		current.GameMode = checked.GameMode
+	}
+	return current
+}
+
+// healthcheckServer immediately healthchecks a single server, records any
+// changes, and returns the updated server. The server is removed if it was
+// renamed to a banned name. False is returned if the server is not known.
+func (r *registry) healthcheckServer(
+	id string,
+	o origin,
+	onHealthy func(GameServer),
+	onUnhealthy func(GameServer),
+) (GameServer, bool) {
+	r.GameServerMapLock.RLock()
+	server, ok := r.GameServerMap[id]
+	r.GameServerMapLock.RUnlock()
+	if !ok {
+		return GameServer{}, false
+	}
+
+	server = r.updateServerHealth(server, onHealthy, onUnhealthy)
+	merged := r.mergeHealthchecks(GameServerMap{id: server}, o)
+	r.removeBannedServer(id, o)
+	server, ok = merged[id]
+	return server, ok
 }
 
 func (r *registry) updateServerHealth(
@@ -142,16 +562,66 @@ func (r *registry) updateServerHealth(
 		// Assume BeaconPort is Port+1000 if we don't know it yet.
 		s.BeaconPort = s.Port + 1000
 	}
-	reportBytes, err := beacon.GetServerReport(s.IP, s.BeaconPort, r.Config.HealthcheckTimeout)
-	if err != nil {
+	return r.applyHealthcheck(s, r.probe(s.IP, s.BeaconPort), onHealthy, onUnhealthy)
+}
+
+// probeResult is the result of a beacon request sent to a server.
+type probeResult struct {
+	data    []byte
+	err     error
+	start   time.Time
+	latency time.Duration
+}
+
+// probe sends a beacon request to the given server.
+func (r *registry) probe(ip string, beaconPort int) probeResult {
+	start := time.Now()
+	data, err := beacon.GetServerReport(ip, beaconPort, r.config().HealthcheckTimeout)
+	return probeResult{data: data, err: err, start: start, latency: time.Since(start)}
+}
+
+// applyHealthcheck updates the health of the given server using the result of
+// a beacon request, records the result in the server's history, and returns
+// the updated server.
+func (r *registry) applyHealthcheck(
+	s GameServer,
+	p probeResult,
+	onHealthy func(GameServer),
+	onUnhealthy func(GameServer),
+) GameServer {
+	serverID := fmt.Sprintf("%s:%d", s.IP, s.Port)
+	if p.err != nil {
+		r.history.Record(serverID, p.start, false, 0, 0)
+		r.metrics.observeHealthcheck(p, false)
+		if s.Health.FailedChecks == 0 || s.Health.FailingSince.IsZero() {
+			s.Health.FailingSince = p.start
+		}
 		s.Health.PassedChecks = 0 // 0 checks in a row have passed
 		s.Health.FailedChecks++   // Another check in a row has failed
-		if s.Health.FailedChecks == r.Config.HealthcheckUnhealthyThreshold {
+
+		// Thresholds are either a length of time or a number of checks.
+		var (
+			config    = r.config()
+			failing   = time.Since(s.Health.FailingSince)
+			unhealthy = s.Health.FailedChecks == config.HealthcheckUnhealthyThreshold
+			expired   = s.Health.FailedChecks >= config.HealthcheckHiddenThreshold
+		)
+		if config.HealthcheckUnhealthyAfter > 0 {
+			unhealthy = s.Health.Healthy && failing >= config.HealthcheckUnhealthyAfter
+		}
+		if config.HealthcheckExpiredAfter > 0 {
+			expired = failing >= config.HealthcheckExpiredAfter
+		}
+
+		if unhealthy {
 			onUnhealthy(s)
-			s.Health.Healthy = false // Too many failed checks in a row.
+			s.Health.Healthy = false // Failing for too long.
 		}
-		if s.Health.FailedChecks >= r.Config.HealthcheckHiddenThreshold {
-			s.Health.Expired = true // TODO: Prune expired servers.
+		if expired {
+			if !s.Health.Expired || s.ExpiredAt.IsZero() {
+				s.ExpiredAt = time.Now() // Start the retention period.
+			}
+			s.Health.Expired = true // Pruned after Config.ExpiredRetention.
 		}
 		return s
 	}
@@ -159,19 +629,28 @@ func (r *registry) updateServerHealth(
 	// Healthcheck succeeded.
 	s.Health.PassedChecks++   // Another check in a row has passed.
 	s.Health.FailedChecks = 0 // 0 checks in a row have failed.
+	s.Health.FailingSince = time.Time{}
+	s.Health.Expired = false // Expired servers may come back.
+	s.ExpiredAt = time.Time{}
+	s.LastSeen = time.Now()
 
 	// Update name and game mode in case they have changed.
-	report, err := beacon.ParseServerReport(s.IP, reportBytes)
+	report, err := beacon.ParseServerReport(s.IP, p.data)
+	r.metrics.observeHealthcheck(p, err != nil)
 	if err != nil {
 		s.Health.ParseFailed = true
+		r.history.Record(serverID, p.start, true, p.latency, 0)
 	} else {
+		r.history.Record(serverID, p.start, true, p.latency, report.NumPlayers)
 		s.Health.ParseFailed = false
-		s.Name = report.ServerName
-		s.GameMode = ravenshield.GameModes[report.CurrentMode]
+		if !s.Pinned { // Pinned servers keep the name and mode set by an admin.
+			s.Name = report.ServerName
+			s.GameMode = ravenshield.GameModes[report.CurrentMode]
+		}
 	}
 
 	// Mark unhealthy servers healthy again after consecutive successful checks.
-	if !s.Health.Healthy && s.Health.PassedChecks >= r.Config.HealthcheckHealthyThreshold {
+	if !s.Health.Healthy && s.Health.PassedChecks >= r.config().HealthcheckHealthyThreshold {
 		s.Health.Healthy = true // Server is healthy again.
 		onHealthy(s)
 	}
@@ -0,0 +1,149 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// restartSettings are only read when the registry starts, so changes to them
+// are ignored by Reload.
+var restartSettings = map[string]bool{
+	"checkpoint_path":         true,
+	"checkpoint_rotations":    true,
+	"journal_path":            true,
+	"graveyard_path":          true,
+	"ban_list_path":           true,
+	"audit_log_path":          true,
+	"audit_log_size":          true,
+	"audit_log_max_bytes":     true,
+	"history_path":            true,
+	"strict_loading":          true,
+	"http_listen_addr":        true,
+	"udp_listen_addr":         true,
+	"rate_limit_interval":     true,
+	"rate_limit_source_burst": true,
+	"rate_limit_server_burst": true,
+	"udp_workers":             true,
+	"udp_queue_size":          true,
+	"healthcheck_concurrency": true,
+}
+
+// secretSettings are logged without their values.
+var secretSettings = map[string]bool{"admin_token": true}
+
+// config returns a copy of the current configuration.
+func (r *registry) config() Config {
+	r.configLock.RLock()
+	defer r.configLock.RUnlock()
+	return r.Config
+}
+
+// watchConfig returns the current configuration, and a channel which is closed
+// when it next changes.
+func (r *registry) watchConfig() (Config, <-chan struct{}) {
+	r.configLock.RLock()
+	defer r.configLock.RUnlock()
+	return r.Config, r.configChanged
+}
+
+// Reload reads the configuration again using Config.LoadConfig and applies any
+// changed settings, including healthcheck intervals and thresholds, to the
+// running registry. Servers in the seed file which are not already known are
+// then added. Each change is logged.
+func (r *registry) Reload() error {
+	_, _, err := r.reload()
+	return err
+}
+
+// reload implements Reload, returning the names of the changed settings and the
+// number of servers added from the seed file. Skipped seed file lines are
+// returned as LineErrors.
+func (r *registry) reload() ([]string, int, error) {
+	var changed []string
+	if load := r.config().LoadConfig; load != nil {
+		config, err := load()
+		if err == nil {
+			err = config.Validate()
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to reload configuration: %w", err)
+		}
+		changed = r.applyConfig(config)
+	}
+
+	var added int
+	var err error
+	if seedPath := r.config().SeedPath; seedPath != "" {
+		added, err = r.MergeServers(seedPath)
+		var skipped LineErrors
+		if err != nil && !errors.As(err, &skipped) {
+			return changed, added, fmt.Errorf("failed to reload seed file: %w", err)
+		}
+	}
+
+	r.logger.Info("reloaded configuration", "changed", len(changed), "added", added)
+	return changed, added, err
+}
+
+// applyConfig copies the settings which can change while running from the
+// given Config, and returns the names of those which changed.
+func (r *registry) applyConfig(config Config) []string {
+	r.configLock.Lock()
+	defer r.configLock.Unlock()
+
+	var (
+		changed []string
+		current = reflect.ValueOf(&r.Config).Elem()
+		next    = reflect.ValueOf(config)
+	)
+	for i := 0; i < current.NumField(); i++ {
+		f := current.Type().Field(i)
+		if !isSetting(f.Type) || current.Field(i).Equal(next.Field(i)) {
+			continue
+		}
+
+		name := settingName(f.Name)
+		if restartSettings[name] {
+			r.logger.Warn("ignoring change to setting which requires a restart", "setting", name)
+			continue
+		}
+		if secretSettings[name] {
+			r.logger.Info("setting changed", "setting", name)
+		} else {
+			r.logger.Info("setting changed", "setting", name, "old", current.Field(i).Interface(), "new", next.Field(i).Interface())
+		}
+		current.Field(i).Set(next.Field(i))
+		changed = append(changed, name)
+	}
+
+	if len(changed) > 0 {
+		close(r.configChanged)
+		r.configChanged = make(chan struct{})
+	}
+	return changed
+}
+
+// reloadHandler handles requests to the admin reload endpoint, responding with
+// the changed settings and the number of servers added from the seed file.
+func (r *registry) reloadHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("request method must be POST"))
+		return
+	}
+
+	changed, added, err := r.reload()
+	var skipped LineErrors
+	if err != nil && !errors.As(err, &skipped) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if changed == nil {
+		changed = []string{}
+	}
+	writeJSONValue(w, http.StatusOK, map[string]any{"changed": changed, "added": added, "skipped": len(skipped)})
+}
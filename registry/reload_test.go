@@ -0,0 +1,80 @@
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	seedPath := filepath.Join(t.TempDir(), "seed.csv")
+	seed := "name,ip,port,mode\nKnown,203.0.113.7,6777,coop\nNew,203.0.113.8,6777,coop"
+	if err := os.WriteFile(seedPath, []byte(seed), 0644); err != nil {
+		t.Log("failed to write seed file:", err)
+		t.FailNow()
+	}
+
+	config := Config{
+		SeedPath:                      seedPath,
+		AdminToken:                    "secret",
+		CheckpointInterval:            time.Minute,
+		HealthcheckInterval:           30 * time.Second,
+		HealthcheckTimeout:            5 * time.Second,
+		HealthcheckHealthyThreshold:   1,
+		HealthcheckUnhealthyThreshold: 60,
+		HealthcheckHiddenThreshold:    5760,
+		HTTPListenAddr:                "127.0.0.1:8080",
+	}
+	next := config
+	next.HealthcheckInterval = time.Minute
+	next.HealthcheckUnhealthyThreshold = 30
+	next.HTTPListenAddr = "127.0.0.1:9090" // Requires a restart.
+	next.HealthcheckConcurrency = 8        // Requires a restart.
+	config.LoadConfig = func() (Config, error) { return next, nil }
+
+	reg := NewRegistry(config).(*registry)
+	known := GameServer{Name: "Known", IP: "203.0.113.7", Port: 6777, GameMode: "coop"}
+	known.Health.Healthy = true
+	reg.GameServerMap["203.0.113.7:6777"] = known
+	_, changed := reg.watchConfig()
+
+	rec := adminRequest(reg.newHTTPHandler(), "secret", http.MethodPost, "/admin/reload", "")
+	var response struct {
+		Changed []string `json:"changed"`
+		Added   int      `json:"added"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || rec.Code != http.StatusOK {
+		t.Logf("unexpected response %d: %s", rec.Code, rec.Body)
+		t.FailNow()
+	}
+	if len(response.Changed) != 2 || response.Added != 1 {
+		t.Logf("expected 2 changed settings and 1 added server, got %+v", response)
+		t.FailNow()
+	}
+
+	c := reg.config()
+	if c.HealthcheckInterval != time.Minute || c.HealthcheckUnhealthyThreshold != 30 || c.HTTPListenAddr != "127.0.0.1:8080" || c.HealthcheckConcurrency != 0 {
+		t.Logf("unexpected config after reload: %+v", c)
+		t.FailNow()
+	}
+	select {
+	case <-changed:
+	default:
+		t.Log("expected config watchers to be notified")
+		t.FailNow()
+	}
+	if s := reg.GameServerMap["203.0.113.7:6777"]; !s.Health.Healthy {
+		t.Log("expected known server to keep its health")
+		t.FailNow()
+	}
+
+	// Invalid configurations are rejected without changing any settings.
+	next.HealthcheckHiddenThreshold = 10
+	if err := reg.Reload(); err == nil || reg.config().HealthcheckHiddenThreshold != 5760 {
+		t.Log("expected invalid configuration to be rejected, got:", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,190 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultListenAddr is used when Config.HTTPListenAddr or
+	// Config.UDPListenAddr is empty.
+	defaultListenAddr = ":8080"
+
+	// defaultShutdownTimeout is used when Config.ShutdownTimeout is zero.
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+// Run starts every registry service: periodic checkpoints, periodic
+// healthchecks, the UDP beacon listener and the HTTP listener. It blocks until
+// ctx is cancelled, Shutdown is called, or a listener fails. It then stops
+// accepting beacons, waits for in-flight healthchecks and registrations, shuts
+// down the HTTP server gracefully and writes a final checkpoint. The error from
+// a failed listener or final checkpoint is returned.
+func (r *registry) Run(ctx context.Context) error {
+	if !r.running.CompareAndSwap(false, true) {
+		return errors.New("registry is already running")
+	}
+	defer close(r.doneCh)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-r.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var (
+		wg      sync.WaitGroup
+		errCh   = make(chan error, 2)
+		udpStop = make(chan struct{})
+		udpAddr = listenAddr(r.config().UDPListenAddr)
+		server  = &http.Server{Addr: listenAddr(r.config().HTTPListenAddr), Handler: r.newHTTPHandler()}
+	)
+
+	// Start listening for beacons from OpenRVS servers.
+	r.logger.Info("listening for beacons", "addr", "udp://"+udpAddr)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := r.HandleUDP(udpAddr, r.handleBeacon, udpStop); err != nil {
+			errCh <- err
+		}
+	}()
+
+	// Start listening for HTTP requests from OpenRVS clients.
+	r.logger.Info("listening for http requests", "addr", "http://"+server.Addr)
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		r.runHealthchecks(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		r.runCheckpoints(ctx)
+	}()
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case runErr = <-errCh:
+		cancel()
+	}
+
+	r.logger.Info("shutting down")
+	timeout := r.config().ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), timeout)
+	defer cancelShutdown()
+
+	// Stop accepting beacons and HTTP requests, then wait for in-flight work.
+	close(udpStop)
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		r.logger.Error("failed to shut down http listener", "error", err)
+	}
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-shutdownCtx.Done():
+		r.logger.Warn("timed out waiting for healthchecks and registrations", "timeout", timeout)
+	}
+
+	r.logger.Info("saving final checkpoint")
+	if err := r.Checkpoint(); err != nil {
+		return errors.Join(runErr, err)
+	}
+	return runErr
+}
+
+// Shutdown stops a running registry, and waits until Run has returned or ctx is
+// done.
+func (r *registry) Shutdown(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stopCh) })
+	if !r.running.Load() {
+		return nil
+	}
+
+	select {
+	case <-r.doneCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runCheckpoints saves checkpoints at the configured interval until ctx is
+// done. When a custom Store is configured, servers added to it by other
+// registries are also loaded after each checkpoint.
+func (r *registry) runCheckpoints(ctx context.Context) {
+	if r.config().CheckpointInterval <= 0 {
+		return
+	}
+	config, changed := r.watchConfig()
+	ticker := time.NewTicker(config.CheckpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+			interval := config.CheckpointInterval
+			if config, changed = r.watchConfig(); config.CheckpointInterval != interval {
+				ticker.Reset(config.CheckpointInterval)
+			}
+			continue
+		case <-ticker.C:
+		}
+
+		r.logger.Debug("saving checkpoint")
+		if err := r.Checkpoint(); err != nil {
+			r.logger.Error("failed to write checkpoint", "error", err)
+		}
+		if r.config().Store != nil {
+			var skipped LineErrors
+			if err := r.Restore(); err != nil && !errors.As(err, &skipped) {
+				r.logger.Error("failed to read stored servers", "error", err)
+			}
+		}
+	}
+}
+
+// handleBeacon registers the server which sent a beacon.
+func (r *registry) handleBeacon(addr *net.UDPAddr, data []byte, err error) {
+	if err != nil {
+		r.logger.Warn("failed to receive beacon", "error", err)
+		return
+	}
+	sourceIP := addr.IP.String()
+	r.logger.Debug("received beacon", "source_ip", sourceIP)
+	if err := r.addServer(addr, data); err != nil {
+		r.logger.Info("rejected beacon", "source_ip", sourceIP, "error", err)
+		return
+	}
+	r.logger.Debug("accepted beacon", "source_ip", sourceIP, "servers", r.ServerCount())
+}
+
+// listenAddr returns the given listen address, or the default when empty.
+func listenAddr(addr string) string {
+	if addr == "" {
+		return defaultListenAddr
+	}
+	return addr
+}
@@ -0,0 +1,110 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunShutdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.csv")
+	reg := NewRegistry(Config{
+		CheckpointPath:      path,
+		CheckpointInterval:  time.Minute,
+		HealthcheckInterval: time.Minute,
+		HealthcheckTimeout:  time.Second,
+		HTTPListenAddr:      "127.0.0.1:0",
+		UDPListenAddr:       "127.0.0.1:0",
+	}).(*registry)
+	reg.GameServerMap["127.0.0.1:6777"] = GameServer{Name: "MyServer", IP: "127.0.0.1", Port: 6777}
+
+	runErr := make(chan error)
+	go func() { runErr <- reg.Run(context.Background()) }()
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := reg.Shutdown(ctx); err != nil {
+		t.Log("failed to shut down:", err)
+		t.FailNow()
+	}
+	if err := <-runErr; err != nil {
+		t.Log("unexpected error from Run:", err)
+		t.FailNow()
+	}
+
+	// A final checkpoint should have been written.
+	if _, err := os.Stat(path); err != nil {
+		t.Log("expected final checkpoint:", err)
+		t.FailNow()
+	}
+}
+
+func TestRunContextCancelled(t *testing.T) {
+	reg := NewRegistry(Config{
+		CheckpointPath: filepath.Join(t.TempDir(), "checkpoint.csv"),
+		HTTPListenAddr: "127.0.0.1:0",
+		UDPListenAddr:  "127.0.0.1:0",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+	go func() { runErr <- reg.Run(ctx) }()
+	cancel()
+
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Log("unexpected error from Run:", err)
+			t.FailNow()
+		}
+	case <-time.After(5 * time.Second):
+		t.Log("Run did not return after its context was cancelled")
+		t.FailNow()
+	}
+}
+
+func TestHandleBeaconLogging(t *testing.T) {
+	const mode = "RGM_TerroristHuntCoopMode"
+	beaconPort := startTestBeacon(t, "Logged", mode)
+
+	var buf bytes.Buffer
+	reg := NewRegistry(Config{
+		HealthcheckTimeout: time.Second,
+		Logger:             slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}).(*registry)
+
+	source := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1234}
+	reg.handleBeacon(source, []byte("not a beacon"), nil)
+	reg.handleBeacon(source, newTestReport("Logged", beaconPort-1000, beaconPort, mode), nil)
+
+	records := make(map[string]map[string]any)
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var record map[string]any
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Logf("invalid log line %q: %v", line, err)
+			t.FailNow()
+		}
+		records[record["msg"].(string)] = record
+	}
+
+	rejected := records["rejected beacon"]
+	if rejected == nil || rejected["level"] != "INFO" || rejected["source_ip"] != "127.0.0.1" || rejected["error"] == nil {
+		t.Logf("unexpected log for rejected beacon: %v", rejected)
+		t.FailNow()
+	}
+
+	changed := records["server list changed"]
+	id := fmt.Sprintf("127.0.0.1:%d", beaconPort-1000)
+	if changed == nil || changed["event"] != string(EventServerAdded) || changed["server_id"] != id || changed["source_ip"] != "127.0.0.1" {
+		t.Logf("unexpected log for registration: %v", changed)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,308 @@
+package registry
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultHealthcheckConcurrency is used when Config.HealthcheckConcurrency
+	// is zero.
+	defaultHealthcheckConcurrency = 64
+
+	// healthcheckJitter is the fraction of the healthcheck interval by which
+	// each healthcheck is randomly moved earlier or later.
+	healthcheckJitter = 0.1
+
+	// defaultHealthcheckMaxInterval is used when Config.HealthcheckMaxInterval
+	// is zero.
+	defaultHealthcheckMaxInterval = 24 * time.Hour
+
+	// schedulerSyncInterval is how often the scheduler looks for new servers.
+	schedulerSyncInterval = time.Second
+)
+
+// scheduledCheck is a healthcheck which is due at a given time.
+type scheduledCheck struct {
+	id   string
+	base time.Time // When the check is due before jitter is applied.
+	due  time.Time
+}
+
+// completedCheck is a healthcheck which has finished, along with the interval
+// until the server's next healthcheck.
+type completedCheck struct {
+	scheduledCheck
+	interval time.Duration
+}
+
+// checkQueue is a min-heap of scheduled healthchecks, ordered by due time.
+type checkQueue []scheduledCheck
+
+func (q checkQueue) Len() int           { return len(q) }
+func (q checkQueue) Less(i, j int) bool { return q[i].due.Before(q[j].due) }
+func (q checkQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *checkQueue) Push(x any)        { *q = append(*q, x.(scheduledCheck)) }
+func (q *checkQueue) Pop() any {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// healthcheckScheduler keeps every server on its own healthcheck cadence. New
+// servers are given a phase within the interval based on their ID, so checks
+// are spread evenly over the interval instead of being sent all at once, and
+// each check is moved by a small random jitter so that servers don't stay in
+// lockstep. It is not safe for concurrent use.
+type healthcheckScheduler struct {
+	queue     checkQueue
+	scheduled map[string]bool      // Includes servers whose check is in progress.
+	due       map[string]time.Time // Of each server's queued check. Others are stale.
+	rand      *rand.Rand
+}
+
+func newHealthcheckScheduler(seed int64) *healthcheckScheduler {
+	return &healthcheckScheduler{
+		scheduled: make(map[string]bool),
+		due:       make(map[string]time.Time),
+		rand:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Add schedules the first healthcheck of a server which is not yet scheduled,
+// within the given interval from now. When a server is waiting for a check
+// which is later than the interval allows, such as an expired server which has
+// registered again, the check is brought forward in the same way.
+func (s *healthcheckScheduler) Add(id string, now time.Time, interval time.Duration) {
+	if s.scheduled[id] {
+		due, queued := s.due[id]
+		latest := now.Add(interval + 2*maxJitter(interval))
+		if !queued || !due.After(latest) {
+			return
+		}
+	}
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	phase := time.Duration(h.Sum64() % uint64(interval))
+
+	base := now.Truncate(interval).Add(phase)
+	if base.Before(now) {
+		base = base.Add(interval)
+	}
+	s.scheduled[id] = true
+	s.push(id, base, interval)
+}
+
+// Reschedule schedules the next healthcheck of a server one interval after the
+// base time of its previous check. Checks which are overdue, such as when the
+// concurrency limit has been reached, are skipped so that the server keeps its
+// phase.
+func (s *healthcheckScheduler) Reschedule(c scheduledCheck, now time.Time, interval time.Duration) {
+	base := c.base.Add(interval)
+	for base.Before(now) {
+		base = base.Add(interval)
+	}
+	s.push(c.id, base, interval)
+}
+
+// Remove forgets a server which is no longer in the server list.
+func (s *healthcheckScheduler) Remove(id string) {
+	delete(s.scheduled, id)
+	delete(s.due, id)
+}
+
+// Next returns the check which is due soonest, or false if no checks are
+// scheduled. Checks which have been brought forward by Add are discarded.
+func (s *healthcheckScheduler) Next() (scheduledCheck, bool) {
+	for len(s.queue) > 0 {
+		c := s.queue[0]
+		if due, ok := s.due[c.id]; ok && due.Equal(c.due) {
+			return c, true
+		}
+		heap.Pop(&s.queue)
+	}
+	return scheduledCheck{}, false
+}
+
+// Pop removes the check returned by Next.
+func (s *healthcheckScheduler) Pop() {
+	c := heap.Pop(&s.queue).(scheduledCheck)
+	delete(s.due, c.id)
+}
+
+func (s *healthcheckScheduler) push(id string, base time.Time, interval time.Duration) {
+	var jitter time.Duration
+	if max := int64(maxJitter(interval)); max > 0 {
+		jitter = time.Duration(s.rand.Int63n(2*max+1) - max)
+	}
+	c := scheduledCheck{id: id, base: base, due: base.Add(jitter)}
+	s.due[id] = c.due
+	heap.Push(&s.queue, c)
+}
+
+// maxJitter returns the most by which a check with the given interval may be
+// moved earlier or later.
+func maxJitter(interval time.Duration) time.Duration {
+	return time.Duration(float64(interval) * healthcheckJitter)
+}
+
+// runHealthchecks healthchecks every server at the interval for its state until
+// ctx is done, spreading the healthchecks over the interval and running at most
+// Config.HealthcheckConcurrency at once. Healthchecks which are in progress are
+// always completed. Banned and expired servers are removed once per
+// HealthcheckInterval. Whenever a server's interval becomes shorter, such as
+// when it recovers or Reload changes the intervals, a check which is further
+// away than the new interval is brought forward.
+func (r *registry) runHealthchecks(ctx context.Context) {
+	if r.config().HealthcheckInterval <= 0 {
+		return
+	}
+	r.logger.Info("sending healthchecks", "interval", r.config().HealthcheckInterval)
+
+	var (
+		o         = origin{channel: ChannelHealthcheck}
+		scheduler = newHealthcheckScheduler(time.Now().UnixNano())
+		slots     = make(chan struct{}, r.healthcheckConcurrency())
+		completed = make(chan completedCheck)
+		removed   = make(chan scheduledCheck)
+		wg        sync.WaitGroup
+
+		lastSync, lastMaintenance time.Time
+	)
+	defer wg.Wait()
+
+	onHealthy := func(s GameServer) {
+		r.logger.Info("server is now healthy", "server_id", fmt.Sprintf("%s:%d", s.IP, s.Port))
+	}
+	onUnhealthy := func(s GameServer) {
+		r.logger.Warn("server is now unhealthy", "server_id", fmt.Sprintf("%s:%d", s.IP, s.Port))
+	}
+
+	for {
+		now := time.Now()
+		interval := r.config().HealthcheckInterval
+
+		// Banned servers are removed without being healthchecked.
+		if now.Sub(lastMaintenance) >= interval {
+			r.removeBannedServers(o)
+			r.pruneExpiredServers()
+			lastMaintenance = now
+		}
+		if now.Sub(lastSync) >= schedulerSyncInterval {
+			for id, server := range r.servers() {
+				scheduler.Add(id, now, r.healthcheckInterval(server, now))
+			}
+			lastSync = now
+		}
+
+		// Start the next healthcheck when it is due and a slot is free.
+		// Otherwise, wait for it to be due or for the next sync.
+		var (
+			start chan<- struct{}
+			timer *time.Timer
+		)
+		c, ok := scheduler.Next()
+		if ok && !c.due.After(now) {
+			start = slots
+		} else {
+			wait := lastSync.Add(schedulerSyncInterval).Sub(now)
+			if ok && c.due.Sub(now) < wait {
+				wait = c.due.Sub(now)
+			}
+			timer = time.NewTimer(wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case start <- struct{}{}:
+			scheduler.Pop()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				server, ok := r.healthcheckServer(c.id, o, onHealthy, onUnhealthy)
+				if !ok {
+					select {
+					case removed <- c:
+					case <-ctx.Done():
+					}
+					return
+				}
+				select {
+				case completed <- completedCheck{c, r.healthcheckInterval(server, time.Now())}:
+				case <-ctx.Done():
+				}
+			}()
+		case c := <-completed:
+			scheduler.Reschedule(c.scheduledCheck, time.Now(), c.interval)
+		case c := <-removed:
+			scheduler.Remove(c.id)
+		case <-timerC(timer):
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// timerC returns the channel of the given timer, or nil if there is no timer.
+func timerC(t *time.Timer) <-chan time.Time {
+	if t == nil {
+		return nil
+	}
+	return t.C
+}
+
+// healthcheckInterval returns the time until the next healthcheck of the given
+// server. Expired servers back off exponentially: each interval is the initial
+// interval plus the time the server has been expired, which doubles the
+// interval with each check.
+func (r *registry) healthcheckInterval(s GameServer, now time.Time) time.Duration {
+	config := r.config()
+	healthy := config.HealthcheckInterval
+	unhealthy := config.HealthcheckUnhealthyInterval
+	if unhealthy <= 0 {
+		unhealthy = healthy
+	}
+	expired := config.HealthcheckExpiredInterval
+	if expired <= 0 {
+		expired = unhealthy
+	}
+	max := config.HealthcheckMaxInterval
+	if max <= 0 {
+		max = defaultHealthcheckMaxInterval
+	}
+
+	var interval time.Duration
+	switch {
+	case s.Health.Expired:
+		interval = expired
+		if !s.ExpiredAt.IsZero() && now.After(s.ExpiredAt) {
+			interval += now.Sub(s.ExpiredAt)
+		}
+	case !s.Health.Healthy:
+		interval = unhealthy
+	default:
+		interval = healthy
+	}
+	if interval > max {
+		interval = max
+	}
+	return interval
+}
+
+// healthcheckConcurrency returns the maximum number of healthchecks which may
+// be in progress at once.
+func (r *registry) healthcheckConcurrency() int {
+	if n := r.config().HealthcheckConcurrency; n > 0 {
+		return n
+	}
+	return defaultHealthcheckConcurrency
+}
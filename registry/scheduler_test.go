@@ -0,0 +1,237 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHealthcheckScheduler_Spread(t *testing.T) {
+	const (
+		servers  = 3000
+		interval = 30 * time.Second
+	)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	s := newHealthcheckScheduler(1)
+	for i := 0; i < servers; i++ {
+		s.Add(fmt.Sprintf("203.0.%d.%d:6777", i/256, i%256), now, interval)
+	}
+
+	// Count the checks due in each second of the interval.
+	var counts [30]int
+	for {
+		c, ok := s.Next()
+		if !ok {
+			break
+		}
+		s.Pop()
+		if c.base.Before(now) || !c.base.Before(now.Add(interval)) {
+			t.Logf("expected the first check within one interval, got %s", c.base)
+			t.FailNow()
+		}
+		if jitter := c.due.Sub(c.base); jitter < -interval/10 || jitter > interval/10 {
+			t.Logf("unexpected jitter %s", jitter)
+			t.FailNow()
+		}
+		counts[c.base.Sub(now)/time.Second]++
+	}
+
+	for i, n := range counts {
+		if n < servers/30/2 || n > servers/30*3/2 {
+			t.Logf("expected about %d checks in second %d, got %d", servers/30, i, n)
+			t.FailNow()
+		}
+	}
+}
+
+func TestHealthcheckScheduler_Cadence(t *testing.T) {
+	const interval = 30 * time.Second
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	s := newHealthcheckScheduler(1)
+	s.Add("203.0.113.7:6777", now, interval)
+	first, _ := s.Next()
+	s.Pop()
+
+	// Each check is due one interval after the previous one.
+	s.Reschedule(first, first.due.Add(time.Second), interval)
+	second, _ := s.Next()
+	s.Pop()
+	if !second.base.Equal(first.base.Add(interval)) {
+		t.Logf("expected next check at %s, got %s", first.base.Add(interval), second.base)
+		t.FailNow()
+	}
+
+	// Overdue checks are skipped, keeping the same phase.
+	s.Reschedule(second, second.base.Add(5*interval/2), interval)
+	third, _ := s.Next()
+	if !third.base.Equal(second.base.Add(3 * interval)) {
+		t.Logf("expected next check at %s, got %s", second.base.Add(3*interval), third.base)
+		t.FailNow()
+	}
+}
+
+func TestHealthcheckScheduler_BringForward(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	s := newHealthcheckScheduler(1)
+	s.Add("203.0.113.7:6777", now, 24*time.Hour)
+	first, _ := s.Next()
+
+	// A check within the interval is kept.
+	s.Add("203.0.113.7:6777", now, first.due.Sub(now)*2)
+	if c, _ := s.Next(); c != first {
+		t.Logf("expected check at %s to be kept, got %s", first.due, c.due)
+		t.FailNow()
+	}
+
+	// A check beyond the interval is replaced by one within it.
+	s.Add("203.0.113.7:6777", now.Add(time.Hour), 30*time.Second)
+	c, _ := s.Next()
+	s.Pop()
+	if c.base.Before(now.Add(time.Hour)) || c.base.After(now.Add(time.Hour+30*time.Second)) {
+		t.Logf("expected check to be brought forward, got %s", c.base)
+		t.FailNow()
+	}
+	if c, ok := s.Next(); ok {
+		t.Logf("expected replaced check to be discarded, got %s", c.due)
+		t.FailNow()
+	}
+}
+
+func TestRunHealthchecks_ExpiredServerReturns(t *testing.T) {
+	const mode = "RGM_TerroristHuntCoopMode"
+	beaconPort := startTestBeacon(t, "Returned", mode)
+	reg := NewRegistry(Config{
+		HealthcheckInterval:           200 * time.Millisecond,
+		HealthcheckTimeout:            100 * time.Millisecond,
+		HealthcheckHealthyThreshold:   1,
+		HealthcheckUnhealthyThreshold: 60,
+		HealthcheckHiddenThreshold:    5760,
+	}).(*registry)
+	id := fmt.Sprintf("127.0.0.1:%d", beaconPort-1000)
+	expired := GameServer{IP: "127.0.0.1", Port: beaconPort - 1000, BeaconPort: beaconPort, ExpiredAt: time.Now().Add(-7 * 24 * time.Hour)}
+	expired.Health.Expired = true
+	reg.GameServerMap[id] = expired
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		reg.runHealthchecks(ctx)
+		close(done)
+	}()
+
+	// The expired server is scheduled for a day later, until it registers
+	// again and is healthchecked at the healthy interval.
+	time.Sleep(100 * time.Millisecond)
+	if err := reg.AddServer("127.0.0.1", newTestReport("Returned", beaconPort-1000, beaconPort, mode)); err != nil {
+		t.Log("failed to add server:", err)
+		t.FailNow()
+	}
+	<-done
+
+	if s := reg.servers()[id]; !s.Health.Healthy || s.Health.PassedChecks < 3 {
+		t.Logf("expected repeated healthchecks after registering again, got %+v", s)
+		t.FailNow()
+	}
+}
+
+func TestRunHealthchecks(t *testing.T) {
+	const mode = "RGM_TerroristHuntCoopMode"
+	beaconPort := startTestBeacon(t, "Scheduled", mode)
+	reg := NewRegistry(Config{
+		HealthcheckInterval:           200 * time.Millisecond,
+		HealthcheckTimeout:            100 * time.Millisecond,
+		HealthcheckHealthyThreshold:   1,
+		HealthcheckUnhealthyThreshold: 60,
+		HealthcheckHiddenThreshold:    5760,
+		HealthcheckConcurrency:        1,
+	}).(*registry)
+	id := fmt.Sprintf("127.0.0.1:%d", beaconPort-1000)
+	reg.GameServerMap[id] = GameServer{IP: "127.0.0.1", Port: beaconPort - 1000, BeaconPort: beaconPort}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	reg.runHealthchecks(ctx)
+
+	// About 7 checks are due while running.
+	s := reg.GameServerMap[id]
+	if !s.Health.Healthy || s.Name != "Scheduled" || s.Health.PassedChecks < 4 || s.Health.PassedChecks > 9 {
+		t.Logf("expected repeated successful healthchecks, got %+v", s)
+		t.FailNow()
+	}
+}
+
+func TestHealthcheckInterval(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	reg := NewRegistry(Config{
+		HealthcheckInterval:          30 * time.Second,
+		HealthcheckUnhealthyInterval: 2 * time.Minute,
+		HealthcheckExpiredInterval:   30 * time.Minute,
+		HealthcheckMaxInterval:       24 * time.Hour,
+	}).(*registry)
+
+	healthy := GameServer{Health: GameServerHealthStatus{Healthy: true}}
+	unhealthy := GameServer{}
+	expired := GameServer{Health: GameServerHealthStatus{Expired: true}, ExpiredAt: now.Add(-time.Hour)}
+	longExpired := GameServer{Health: GameServerHealthStatus{Expired: true}, ExpiredAt: now.Add(-30 * 24 * time.Hour)}
+
+	for _, tc := range []struct {
+		name     string
+		server   GameServer
+		expected time.Duration
+	}{
+		{"healthy", healthy, 30 * time.Second},
+		{"unhealthy", unhealthy, 2 * time.Minute},
+		{"expired", expired, 90 * time.Minute},
+		{"expired for a long time", longExpired, 24 * time.Hour},
+	} {
+		if interval := reg.healthcheckInterval(tc.server, now); interval != tc.expected {
+			t.Logf("%s: expected interval %s, got %s", tc.name, tc.expected, interval)
+			t.FailNow()
+		}
+	}
+
+	// Unset intervals default to the interval for the previous state.
+	reg = NewRegistry(Config{HealthcheckInterval: 30 * time.Second}).(*registry)
+	if interval := reg.healthcheckInterval(GameServer{Health: GameServerHealthStatus{Expired: true}}, now); interval != 30*time.Second {
+		t.Logf("expected default interval for expired servers, got %s", interval)
+		t.FailNow()
+	}
+}
+
+func TestApplyHealthcheck_TimeThresholds(t *testing.T) {
+	reg := NewRegistry(Config{
+		HealthcheckUnhealthyAfter: 30 * time.Minute,
+		HealthcheckExpiredAfter:   48 * time.Hour,
+	}).(*registry)
+	failed := probeResult{err: errors.New("timeout"), start: time.Now()}
+	var unhealthyCalls int
+	onUnhealthy := func(GameServer) { unhealthyCalls++ }
+
+	// The first failed check starts the clock.
+	s := GameServer{IP: "203.0.113.7", Port: 6777, Health: GameServerHealthStatus{Healthy: true}}
+	s = reg.applyHealthcheck(s, failed, func(GameServer) {}, onUnhealthy)
+	if !s.Health.Healthy || s.Health.FailingSince.IsZero() {
+		t.Logf("expected server to stay healthy after one failed check, got %+v", s.Health)
+		t.FailNow()
+	}
+
+	// Only the time since the first failed check matters, not the number of
+	// failed checks.
+	s.Health.FailingSince = time.Now().Add(-31 * time.Minute)
+	s = reg.applyHealthcheck(s, failed, func(GameServer) {}, onUnhealthy)
+	s = reg.applyHealthcheck(s, failed, func(GameServer) {}, onUnhealthy)
+	if s.Health.Healthy || s.Health.Expired || unhealthyCalls != 1 {
+		t.Logf("expected server to become unhealthy once, got %+v after %d calls", s.Health, unhealthyCalls)
+		t.FailNow()
+	}
+
+	s.Health.FailingSince = time.Now().Add(-49 * time.Hour)
+	s = reg.applyHealthcheck(s, failed, func(GameServer) {}, onUnhealthy)
+	if !s.Health.Expired || s.ExpiredAt.IsZero() || s.Health.FailedChecks != 4 {
+		t.Logf("expected server to expire, got %+v", s)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,242 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// EnvPrefix is prepended to the upper-case name of each setting to form the
+// name of the environment variable which sets it, e.g. OPENRVS_ADMIN_TOKEN.
+const EnvPrefix = "OPENRVS_"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Settings returns the names of the Config fields which can be set from a
+// config file, environment variables or flags. Names are the field names in
+// snake_case, e.g. healthcheck_interval. Store and Logger can only be set in
+// code.
+func Settings() []string {
+	t := reflect.TypeOf(Config{})
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		if isSetting(t.Field(i).Type) {
+			names = append(names, settingName(t.Field(i).Name))
+		}
+	}
+	return names
+}
+
+// Set parses value and assigns it to the setting with the given name. Durations
+// use the format accepted by time.ParseDuration, e.g. "30s".
+func (c *Config) Set(name, value string) error {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !isSetting(f.Type) || settingName(f.Name) != name {
+			continue
+		}
+		if err := setValue(v.Field(i), value); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", value, name, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown setting %q", name)
+}
+
+// LoadFile applies the settings in a JSON config file, which contains an object
+// keyed by setting name. Values may be JSON strings, numbers or booleans.
+func (c *Config) LoadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]json.RawMessage
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&values); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		raw := values[name]
+		value := string(raw)
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			value = s
+		}
+		if err := c.Set(name, value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LoadEnv applies any settings found in environment variables by lookup, which
+// is normally os.LookupEnv.
+func (c *Config) LoadEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+	for _, name := range Settings() {
+		if value, ok := lookup(EnvPrefix + strings.ToUpper(name)); ok {
+			if err := c.Set(name, value); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate returns an error describing every value in the Config which would
+// prevent the registry from running sensibly. Zero values which select a
+// default are accepted.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.HealthcheckInterval <= 0 {
+		invalid("healthcheck_interval must be positive")
+	}
+	if c.HealthcheckTimeout <= 0 {
+		invalid("healthcheck_timeout must be positive")
+	} else if c.HealthcheckInterval > 0 && c.HealthcheckTimeout >= c.HealthcheckInterval {
+		invalid("healthcheck_timeout (%s) must be less than healthcheck_interval (%s)", c.HealthcheckTimeout, c.HealthcheckInterval)
+	}
+	if c.CheckpointInterval <= 0 {
+		invalid("checkpoint_interval must be positive")
+	}
+
+	if c.HealthcheckMaxInterval > 0 && c.HealthcheckMaxInterval < c.HealthcheckInterval {
+		invalid("healthcheck_max_interval (%s) must not be less than healthcheck_interval (%s)", c.HealthcheckMaxInterval, c.HealthcheckInterval)
+	}
+
+	// Count thresholds only apply when the matching time threshold is zero.
+	if c.HealthcheckHealthyThreshold < 1 {
+		invalid("healthcheck_healthy_threshold must be at least 1")
+	}
+	if c.HealthcheckUnhealthyAfter == 0 && c.HealthcheckUnhealthyThreshold < 1 {
+		invalid("healthcheck_unhealthy_threshold must be at least 1")
+	}
+	if c.HealthcheckExpiredAfter == 0 && c.HealthcheckHiddenThreshold < 1 {
+		invalid("healthcheck_hidden_threshold must be at least 1")
+	}
+	switch {
+	case c.HealthcheckUnhealthyAfter > 0 && c.HealthcheckExpiredAfter > 0:
+		if c.HealthcheckExpiredAfter < c.HealthcheckUnhealthyAfter {
+			invalid("healthcheck_expired_after (%s) must not be less than healthcheck_unhealthy_after (%s)", c.HealthcheckExpiredAfter, c.HealthcheckUnhealthyAfter)
+		}
+	case c.HealthcheckUnhealthyAfter == 0 && c.HealthcheckExpiredAfter == 0:
+		if c.HealthcheckHiddenThreshold < c.HealthcheckUnhealthyThreshold {
+			invalid("healthcheck_hidden_threshold (%d) must not be less than healthcheck_unhealthy_threshold (%d)", c.HealthcheckHiddenThreshold, c.HealthcheckUnhealthyThreshold)
+		}
+	}
+
+	// Any other negative number is invalid.
+	checked := map[string]bool{
+		"HealthcheckInterval":           true,
+		"HealthcheckTimeout":            true,
+		"CheckpointInterval":            true,
+		"HealthcheckHealthyThreshold":   true,
+		"HealthcheckUnhealthyThreshold": true,
+		"HealthcheckHiddenThreshold":    true,
+	}
+	v := reflect.ValueOf(c)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if f := v.Field(i); !checked[name] && (f.Kind() == reflect.Int || f.Kind() == reflect.Int64) && f.Int() < 0 {
+			invalid("%s must not be negative", settingName(name))
+		}
+	}
+
+	switch c.RegistrationVerification {
+	case "", VerifyNone, VerifyProbe, VerifySource:
+	default:
+		invalid("registration_verification must be %q, %q or %q", VerifyNone, VerifyProbe, VerifySource)
+	}
+
+	for _, addr := range []struct{ name, value string }{
+		{"http_listen_addr", c.HTTPListenAddr},
+		{"udp_listen_addr", c.UDPListenAddr},
+	} {
+		if _, _, err := net.SplitHostPort(addr.value); addr.value != "" && err != nil {
+			invalid("invalid %s: %w", addr.name, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// isSetting returns true for the types of Config fields which can be parsed
+// from a string.
+func isSetting(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Int, reflect.Bool:
+		return true
+	}
+	return t == durationType
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	}
+	return nil
+}
+
+// settingName converts a Config field name to snake_case, treating runs of
+// capitals as a single word, e.g. UDPQueueSize becomes udp_queue_size.
+func settingName(field string) string {
+	runes := []rune(field)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,121 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSettingName(t *testing.T) {
+	for field, expected := range map[string]string{
+		"SeedPath":                      "seed_path",
+		"HealthcheckUnhealthyThreshold": "healthcheck_unhealthy_threshold",
+		"UDPQueueSize":                  "udp_queue_size",
+		"TrustProxyHeaders":             "trust_proxy_headers",
+	} {
+		if name := settingName(field); name != expected {
+			t.Logf("expected %s for %s, got %s", expected, field, name)
+			t.FailNow()
+		}
+	}
+}
+
+func TestConfigSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	contents := `{"healthcheck_interval": "1m", "healthcheck_unhealthy_threshold": 10, "trust_proxy_headers": true, "admin_token": "file"}`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Log("failed to write config file:", err)
+		t.FailNow()
+	}
+
+	var c Config
+	if err := c.LoadFile(path); err != nil {
+		t.Log("failed to load config file:", err)
+		t.FailNow()
+	}
+	env := map[string]string{"OPENRVS_ADMIN_TOKEN": "env", "OPENRVS_UDP_WORKERS": "4"}
+	if err := c.LoadEnv(func(k string) (string, bool) { v, ok := env[k]; return v, ok }); err != nil {
+		t.Log("failed to load environment:", err)
+		t.FailNow()
+	}
+
+	if c.HealthcheckInterval != time.Minute || c.HealthcheckUnhealthyThreshold != 10 || !c.TrustProxyHeaders {
+		t.Logf("unexpected values from config file: %+v", c)
+		t.FailNow()
+	}
+	if c.AdminToken != "env" || c.UDPWorkers != 4 {
+		t.Logf("expected environment to override config file, got %+v", c)
+		t.FailNow()
+	}
+
+	if err := c.Set("healthcheck_interval", "soon"); err == nil {
+		t.Log("expected error for invalid duration")
+		t.FailNow()
+	}
+	if err := c.Set("no_such_setting", "1"); err == nil {
+		t.Log("expected error for unknown setting")
+		t.FailNow()
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	c := Config{
+		CheckpointInterval:            time.Minute,
+		HealthcheckInterval:           30 * time.Second,
+		HealthcheckTimeout:            5 * time.Second,
+		HealthcheckHealthyThreshold:   1,
+		HealthcheckUnhealthyThreshold: 60,
+		HealthcheckHiddenThreshold:    5760,
+		HTTPListenAddr:                "127.0.0.1:8080",
+	}
+	if err := c.Validate(); err != nil {
+		t.Log("unexpected error for valid config:", err)
+		t.FailNow()
+	}
+
+	c.HealthcheckUnhealthyAfter, c.HealthcheckExpiredAfter = 30*time.Minute, 48*time.Hour
+	if err := c.Validate(); err != nil {
+		t.Log("unexpected error for time thresholds:", err)
+		t.FailNow()
+	}
+	c.HealthcheckExpiredAfter = time.Minute
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "healthcheck_expired_after") {
+		t.Log("expected error for expiry before becoming unhealthy, got:", err)
+		t.FailNow()
+	}
+
+	// Count thresholds are required for any state without a time threshold.
+	mixed := c
+	mixed.HealthcheckUnhealthyAfter, mixed.HealthcheckExpiredAfter = 30*time.Minute, 0
+	mixed.HealthcheckUnhealthyThreshold, mixed.HealthcheckHiddenThreshold = 0, 0
+	if err := mixed.Validate(); err == nil || !strings.Contains(err.Error(), "healthcheck_hidden_threshold must be at least 1") {
+		t.Log("expected error for missing hidden threshold, got:", err)
+		t.FailNow()
+	}
+	mixed.HealthcheckHiddenThreshold = 1
+	if err := mixed.Validate(); err != nil {
+		t.Log("unexpected error for time-based unhealthy and count-based expiry:", err)
+		t.FailNow()
+	}
+	mixed.HealthcheckUnhealthyAfter, mixed.HealthcheckExpiredAfter = 0, 48*time.Hour
+	if err := mixed.Validate(); err == nil || !strings.Contains(err.Error(), "healthcheck_unhealthy_threshold must be at least 1") {
+		t.Log("expected error for missing unhealthy threshold, got:", err)
+		t.FailNow()
+	}
+	mixed.HealthcheckUnhealthyThreshold = 60
+	if err := mixed.Validate(); err != nil {
+		t.Log("unexpected error for count-based unhealthy and time-based expiry:", err)
+		t.FailNow()
+	}
+
+	c.HealthcheckUnhealthyAfter, c.HealthcheckExpiredAfter = 0, 0
+	c.HealthcheckHiddenThreshold = 30
+	c.UDPWorkers = -1
+	err := c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "healthcheck_hidden_threshold") || !strings.Contains(err.Error(), "udp_workers") {
+		t.Log("expected errors for hidden threshold and UDP workers, got:", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,220 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store provides persistent storage for the server list. Save replaces the
+// stored servers with a full snapshot, while Upsert and Delete persist changes
+// to individual servers as they happen.
+type Store interface {
+	Load() (GameServerMap, error)
+	Save(GameServerMap) error
+	Upsert(id string, server GameServer) error
+	Delete(id string) error
+}
+
+// csvStore implements the Store interface with a single checkpoint file.
+type csvStore struct {
+	path      string
+	rotations int
+	csv       CSVSerializer
+}
+
+// NewCSVStore initializes and returns a Store which saves the complete server
+// list to a CSV checkpoint file, keeping the given number of previous versions.
+// Individual changes are not persisted until the next call to Save.
+func NewCSVStore(path string, rotations int, csv CSVSerializer) Store {
+	return &csvStore{
+		path:      path,
+		rotations: rotations,
+		csv:       csv,
+	}
+}
+
+// Load reads the checkpoint file, falling back to each of its previous
+// versions in turn, newest first. Malformed lines are handled as described in
+// CSVSerializer.Deserialize.
+func (s *csvStore) Load() (GameServerMap, error) {
+	var firstErr error
+	for i := 0; i <= s.rotations; i++ {
+		servers, err := s.loadFile(rotatedPath(s.path, i))
+		if servers != nil {
+			return servers, err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+func (s *csvStore) loadFile(path string) (GameServerMap, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	servers, err := s.csv.Deserialize(b)
+	if len(servers) == 0 && err != nil {
+		return nil, fmt.Errorf("no valid servers in %s: %v", path, err)
+	}
+	return servers, err
+}
+
+func (s *csvStore) Save(servers GameServerMap) error {
+	return writeFileAtomic(s.path, s.csv.SerializeCheckpoint(servers), s.rotations)
+}
+
+func (s *csvStore) Upsert(string, GameServer) error {
+	return nil
+}
+
+func (s *csvStore) Delete(string) error {
+	return nil
+}
+
+// dirStore implements the Store interface with one JSON file per server.
+type dirStore struct {
+	dir string
+
+	lock    sync.Mutex
+	modTime map[string]time.Time // Of each file when this store last read or wrote it.
+}
+
+// NewDirStore initializes and returns a Store which keeps each server in its
+// own JSON file in the given directory. Every change is written immediately and
+// atomically, so several registries can share the same directory, subject to
+// the limits described on Save.
+func NewDirStore(dir string) Store {
+	return &dirStore{dir: dir, modTime: make(map[string]time.Time)}
+}
+
+// Load reads every server file in the directory. Files which cannot be read or
+// decoded are skipped and returned as LineErrors, numbered by their position in
+// the directory, after the remaining servers have been loaded.
+func (s *dirStore) Load() (GameServerMap, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		servers = make(GameServerMap)
+		skipped LineErrors
+	)
+	for i, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		path := filepath.Join(s.dir, entry.Name())
+		info, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue // Deleted by another registry since the directory was read.
+		}
+		var b []byte
+		if err == nil {
+			b, err = os.ReadFile(path)
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		var server GameServer
+		if err == nil {
+			err = json.Unmarshal(b, &server)
+		}
+		if err != nil {
+			skipped = append(skipped, LineError{Line: i + 1, Err: fmt.Errorf("invalid server file %s: %w", entry.Name(), err)})
+			continue
+		}
+		servers[fmt.Sprintf("%s:%d", server.IP, server.Port)] = server
+		s.setModTime(path, info.ModTime())
+	}
+
+	if len(skipped) > 0 {
+		return servers, skipped
+	}
+	return servers, nil
+}
+
+// Save writes every server in the given map. Since the map may be out of date
+// when the directory is shared, files which have been changed or removed by
+// another registry since this store last read or wrote them are left as they
+// are, as are files for servers which this store has never read or written.
+// Files for servers which are not in the map are also left in place; use Delete
+// to remove them.
+func (s *dirStore) Save(servers GameServerMap) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	for id, server := range servers {
+		if s.changedElsewhere(s.path(id)) {
+			continue
+		}
+		if err := s.Upsert(id, server); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *dirStore) Upsert(id string, server GameServer) error {
+	b, err := json.Marshal(server)
+	if err != nil {
+		return err
+	}
+	path := s.path(id)
+	if err := writeFileAtomic(path, b, 0); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		s.setModTime(path, info.ModTime())
+	}
+	return nil
+}
+
+func (s *dirStore) Delete(id string) error {
+	path := s.path(id)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	s.lock.Lock()
+	delete(s.modTime, path)
+	s.lock.Unlock()
+	return nil
+}
+
+// changedElsewhere returns true when the file at path is not the version which
+// this store last read or wrote.
+func (s *dirStore) changedElsewhere(path string) bool {
+	s.lock.Lock()
+	modTime, known := s.modTime[path]
+	s.lock.Unlock()
+
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return known // Removed by another registry.
+	}
+	return err != nil || !known || !info.ModTime().Equal(modTime)
+}
+
+func (s *dirStore) setModTime(path string, modTime time.Time) {
+	s.lock.Lock()
+	s.modTime[path] = modTime
+	s.lock.Unlock()
+}
+
+// path returns the file path for the given server ID. Colons are replaced
+// since they are not allowed in file names on Windows.
+func (s *dirStore) path(id string) string {
+	return filepath.Join(s.dir, strings.ReplaceAll(id, ":", "_")+".json")
+}
@@ -0,0 +1,173 @@
+package registry
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDirStore(t *testing.T) {
+	store := NewDirStore(filepath.Join(t.TempDir(), "servers"))
+	servers := GameServerMap{
+		"127.0.0.1:6777": GameServer{
+			Name:      "MyServer",
+			IP:        "127.0.0.1",
+			Port:      6777,
+			GameMode:  "adv",
+			Health:    GameServerHealthStatus{Healthy: true, PassedChecks: 1},
+			FirstSeen: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		"127.0.0.1:7777": GameServer{Name: "Other", IP: "127.0.0.1", Port: 7777},
+	}
+
+	if err := store.Save(servers); err != nil {
+		t.Log("failed to save servers:", err)
+		t.FailNow()
+	}
+	if err := store.Delete("127.0.0.1:7777"); err != nil {
+		t.Log("failed to delete server:", err)
+		t.FailNow()
+	}
+	added := GameServer{Name: "Added", IP: "127.0.0.1", Port: 8777}
+	if err := store.Upsert("127.0.0.1:8777", added); err != nil {
+		t.Log("failed to upsert server:", err)
+		t.FailNow()
+	}
+
+	// A second store sharing the directory should see every change.
+	loaded, err := NewDirStore(store.(*dirStore).dir).Load()
+	if err != nil {
+		t.Log("failed to load servers:", err)
+		t.FailNow()
+	}
+	if len(loaded) != 2 {
+		t.Logf("expected %d servers, got %d", 2, len(loaded))
+		t.FailNow()
+	}
+	if loaded["127.0.0.1:6777"] != servers["127.0.0.1:6777"] {
+		t.Logf("expected %+v, got %+v", servers["127.0.0.1:6777"], loaded["127.0.0.1:6777"])
+		t.FailNow()
+	}
+	if loaded["127.0.0.1:8777"] != added {
+		t.Logf("expected %+v, got %+v", added, loaded["127.0.0.1:8777"])
+		t.FailNow()
+	}
+}
+
+func TestDirStore_Shared(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "servers")
+	a, b := NewDirStore(dir), NewDirStore(dir)
+	servers := GameServerMap{
+		"127.0.0.1:6777": GameServer{Name: "Removed", IP: "127.0.0.1", Port: 6777},
+		"127.0.0.1:7777": GameServer{Name: "Edited", IP: "127.0.0.1", Port: 7777},
+		"127.0.0.1:8777": GameServer{Name: "Unchanged", IP: "127.0.0.1", Port: 8777},
+	}
+	if err := a.Save(servers); err != nil {
+		t.Log("failed to save servers:", err)
+		t.FailNow()
+	}
+	if _, err := b.Load(); err != nil {
+		t.Log("failed to load servers:", err)
+		t.FailNow()
+	}
+
+	// Changes made by one registry are not undone by the other's checkpoint.
+	a.Delete("127.0.0.1:6777")
+	time.Sleep(10 * time.Millisecond) // Ensure the modification time changes.
+	a.Upsert("127.0.0.1:7777", GameServer{Name: "Renamed", IP: "127.0.0.1", Port: 7777})
+	stale := GameServerMap{
+		"127.0.0.1:6777": servers["127.0.0.1:6777"],
+		"127.0.0.1:7777": servers["127.0.0.1:7777"],
+		"127.0.0.1:8777": GameServer{Name: "Saved", IP: "127.0.0.1", Port: 8777},
+	}
+	if err := b.Save(stale); err != nil {
+		t.Log("failed to save servers:", err)
+		t.FailNow()
+	}
+
+	loaded, err := NewDirStore(dir).Load()
+	if err != nil {
+		t.Log("failed to load servers:", err)
+		t.FailNow()
+	}
+	if _, ok := loaded["127.0.0.1:6777"]; ok {
+		t.Log("expected removed server to stay removed")
+		t.FailNow()
+	}
+	if loaded["127.0.0.1:7777"].Name != "Renamed" || loaded["127.0.0.1:8777"].Name != "Saved" {
+		t.Logf("expected only unchanged servers to be saved, got %+v", loaded)
+		t.FailNow()
+	}
+}
+
+func TestDirStore_SkipsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := NewDirStore(dir)
+	store.Upsert("127.0.0.1:6777", GameServer{Name: "Good", IP: "127.0.0.1", Port: 6777})
+	if err := os.WriteFile(filepath.Join(dir, "bad.json"), []byte("{"), 0644); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	servers, err := store.Load()
+	var skipped LineErrors
+	if !errors.As(err, &skipped) || len(skipped) != 1 {
+		t.Log("expected one skipped file, got:", err)
+		t.FailNow()
+	}
+	if len(servers) != 1 {
+		t.Logf("expected %d servers, got %d", 1, len(servers))
+		t.FailNow()
+	}
+}
+
+func TestRestore_MergesStoredServers(t *testing.T) {
+	store := NewDirStore(t.TempDir())
+	store.Upsert("127.0.0.1:6777", GameServer{Name: "Stored", IP: "127.0.0.1", Port: 6777})
+	store.Upsert("127.0.0.1:7777", GameServer{Name: "Peer", IP: "127.0.0.1", Port: 7777})
+
+	reg := NewRegistry(Config{Store: store}).(*registry)
+	reg.GameServerMap["127.0.0.1:6777"] = GameServer{Name: "Local", IP: "127.0.0.1", Port: 6777}
+
+	if err := reg.Restore(); err != nil {
+		t.Log("failed to restore servers:", err)
+		t.FailNow()
+	}
+	if reg.ServerCount() != 2 {
+		t.Logf("incorrect server count; expected %d, got %d", 2, reg.ServerCount())
+		t.FailNow()
+	}
+	if reg.GameServerMap["127.0.0.1:6777"].Name != "Local" {
+		t.Log("known servers should not be overwritten by Restore")
+		t.FailNow()
+	}
+
+	events, _ := reg.audit.Query("", time.Time{}, time.Time{})
+	if len(events) != 1 || events[0].ServerID != "127.0.0.1:7777" || events[0].Channel != ChannelStore {
+		t.Logf("expected one audited server from the store, got %+v", events)
+		t.FailNow()
+	}
+}
+
+func TestCheckpoint_DefaultCSVStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.csv")
+	reg := NewRegistry(Config{CheckpointPath: path}).(*registry)
+	reg.GameServerMap["127.0.0.1:6777"] = GameServer{Name: "MyServer", IP: "127.0.0.1", Port: 6777, BeaconPort: 7777}
+
+	if err := reg.Checkpoint(); err != nil {
+		t.Log("failed to write checkpoint:", err)
+		t.FailNow()
+	}
+
+	restored := NewRegistry(Config{CheckpointPath: path}).(*registry)
+	if err := restored.Restore(); err != nil {
+		t.Log("failed to restore checkpoint:", err)
+		t.FailNow()
+	}
+	if restored.GameServerMap["127.0.0.1:6777"] != reg.GameServerMap["127.0.0.1:6777"] {
+		t.Logf("expected %+v, got %+v", reg.GameServerMap["127.0.0.1:6777"], restored.GameServerMap["127.0.0.1:6777"])
+		t.FailNow()
+	}
+}
@@ -1,8 +1,18 @@
 package registry
 
 import (
-	"fmt"
+	"errors"
 	"net"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// defaultUDPWorkers is used when Config.UDPWorkers is zero.
+	defaultUDPWorkers = 8
+
+	// defaultUDPQueueSize is used when Config.UDPQueueSize is zero.
+	defaultUDPQueueSize = 256
 )
 
 // UDPHandler is the signature of a function for processing incoming UDP
@@ -10,8 +20,30 @@ import (
 // the origin/source address, as well as the request bytes.
 type UDPHandler func(addr *net.UDPAddr, data []byte, err error)
 
-func (r *registry) HandleUDP(port int, h UDPHandler, stopCh chan struct{}) error {
-	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", port))
+// UDPStats contains counters for datagrams received by HandleUDP.
+type UDPStats struct {
+	Received   uint64 // Datagrams read from the socket.
+	Dropped    uint64 // Datagrams dropped because the queue was full.
+	Limited    uint64 // Datagrams dropped by the per-source rate limit.
+	Handled    uint64 // Datagrams processed by a worker.
+	QueueDepth int64  // Datagrams waiting for a worker.
+}
+
+// udpPacket is a single datagram waiting to be handled.
+type udpPacket struct {
+	addr *net.UDPAddr
+	data []byte
+	err  error
+}
+
+// HandleUDP listens for UDP datagrams on the given address until stopCh is closed
+// or receives a value. Datagrams are queued and passed to h by a fixed number
+// of workers, as configured by Config.UDPWorkers and Config.UDPQueueSize.
+// Datagrams which arrive while the queue is full, or which exceed the
+// per-source rate limit, are dropped and counted in UDPStats. HandleUDP waits
+// for queued datagrams to be handled before returning.
+func (r *registry) HandleUDP(listenAddress string, h UDPHandler, stopCh chan struct{}) error {
+	addr, err := net.ResolveUDPAddr("udp", listenAddress)
 	if err != nil {
 		return err
 	}
@@ -22,14 +54,91 @@ func (r *registry) HandleUDP(port int, h UDPHandler, stopCh chan struct{}) error
 	}
 	defer conn.Close()
 
+	// Unblock the pending read when stopCh is closed.
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-stopCh:
+			conn.Close()
+		case <-stopped:
+		}
+	}()
+
+	workers := r.config().UDPWorkers
+	if workers <= 0 {
+		workers = defaultUDPWorkers
+	}
+	queueSize := r.config().UDPQueueSize
+	if queueSize <= 0 {
+		queueSize = defaultUDPQueueSize
+	}
+
+	// Start workers, and wait for them to drain the queue before returning.
+	queue := make(chan udpPacket, queueSize)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range queue {
+				r.udpStats.queueDepth.Add(-1)
+				h(p.addr, p.data, p.err)
+				r.udpStats.handled.Add(1)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(queue)
+
 	buf := make([]byte, 4096)
-	select {
-	case _ = <-stopCh:
-		break
-	default:
+	for {
 		n, addr, err := conn.ReadFromUDP(buf) // Blocking
-		go h(addr, buf[0:n], err)
+		if errors.Is(err, net.ErrClosed) {
+			return nil // Stopped.
+		}
+		r.udpStats.received.Add(1)
+
+		if err == nil && !r.sourceLimiter.Allow(addr.IP.String()) {
+			r.udpStats.limited.Add(1)
+			continue
+		}
+
+		// Copy the datagram, since buf is reused by the next read.
+		p := udpPacket{addr: addr, err: err}
+		if err == nil {
+			p.data = make([]byte, n)
+			copy(p.data, buf[:n])
+		}
+
+		// The depth is counted before sending, so that it can't go negative
+		// when a worker takes the datagram straight away.
+		r.udpStats.queueDepth.Add(1)
+		select {
+		case queue <- p:
+		default:
+			r.udpStats.queueDepth.Add(-1)
+			r.udpStats.dropped.Add(1)
+		}
 	}
+}
+
+// UDPStats returns counters for datagrams received by HandleUDP.
+func (r *registry) UDPStats() UDPStats {
+	return UDPStats{
+		Received:   r.udpStats.received.Load(),
+		Dropped:    r.udpStats.dropped.Load(),
+		Limited:    r.udpStats.limited.Load(),
+		Handled:    r.udpStats.handled.Load(),
+		QueueDepth: r.udpStats.queueDepth.Load(),
+	}
+}
 
-	return nil
+// udpCounters holds the values returned by UDPStats.
+type udpCounters struct {
+	received   atomic.Uint64
+	dropped    atomic.Uint64
+	limited    atomic.Uint64
+	handled    atomic.Uint64
+	queueDepth atomic.Int64
 }
@@ -1,8 +1,11 @@
 package registry
 
 import (
+	"fmt"
 	"net"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestUDP(t *testing.T) {
@@ -15,6 +18,155 @@ func TestUDP(t *testing.T) {
 
 	reg := NewRegistry(Config{})
 	stopCh := make(chan struct{})
-	go reg.HandleUDP(9999, testHandler, stopCh)
+	go reg.HandleUDP(":9999", testHandler, stopCh)
 	stopCh <- struct{}{}
 }
+
+func TestUDP_ReceivesMultipleBeacons(t *testing.T) {
+	const beacons = 5
+
+	var (
+		lock     sync.Mutex
+		received = make(map[string]bool)
+		handled  sync.WaitGroup
+	)
+	handled.Add(beacons)
+	testHandler := func(addr *net.UDPAddr, data []byte, err error) {
+		if err != nil {
+			t.Error("UDP error:", err)
+			return
+		}
+		// Read the data after later datagrams have arrived, to ensure the
+		// receive buffer is not shared between handlers.
+		time.Sleep(50 * time.Millisecond)
+		lock.Lock()
+		received[string(data)] = true
+		lock.Unlock()
+		handled.Done()
+	}
+
+	reg := NewRegistry(Config{})
+	stopCh := make(chan struct{})
+	udpErr := make(chan error)
+	go func() { udpErr <- reg.HandleUDP(":9998", testHandler, stopCh) }()
+	time.Sleep(50 * time.Millisecond) // Wait for the listener to start.
+
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9998})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer conn.Close()
+	for i := 0; i < beacons; i++ {
+		if _, err := conn.Write([]byte(fmt.Sprintf("beacon %d", i))); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	handled.Wait()
+	for i := 0; i < beacons; i++ {
+		if !received[fmt.Sprintf("beacon %d", i)] {
+			t.Logf("beacon %d was not handled", i)
+			t.FailNow()
+		}
+	}
+
+	close(stopCh)
+	select {
+	case err := <-udpErr:
+		if err != nil {
+			t.Log("unexpected error from HandleUDP:", err)
+			t.FailNow()
+		}
+	case <-time.After(5 * time.Second):
+		t.Log("HandleUDP did not return after stopCh was closed")
+		t.FailNow()
+	}
+}
+
+func TestUDP_DropsWhenQueueIsFull(t *testing.T) {
+	const beacons = 10
+
+	unblock := make(chan struct{})
+	testHandler := func(addr *net.UDPAddr, data []byte, err error) {
+		<-unblock
+	}
+
+	reg := NewRegistry(Config{UDPWorkers: 1, UDPQueueSize: 2})
+	stopCh := make(chan struct{})
+	udpErr := make(chan error)
+	go func() { udpErr <- reg.HandleUDP(":9997", testHandler, stopCh) }()
+	time.Sleep(50 * time.Millisecond) // Wait for the listener to start.
+
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9997})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer conn.Close()
+	for i := 0; i < beacons; i++ {
+		conn.Write([]byte("beacon"))
+	}
+
+	// Wait for every datagram to be read from the socket.
+	deadline := time.Now().Add(5 * time.Second)
+	for reg.UDPStats().Received < beacons && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// At most one datagram is held by the worker, and two are queued.
+	stats := reg.UDPStats()
+	if stats.Received != beacons || stats.Dropped < beacons-3 || stats.QueueDepth != 2 {
+		t.Logf("unexpected stats: %+v", stats)
+		t.FailNow()
+	}
+
+	close(unblock)
+	close(stopCh)
+	<-udpErr
+
+	stats = reg.UDPStats()
+	if stats.Handled+stats.Dropped != beacons || stats.QueueDepth != 0 {
+		t.Logf("unexpected stats after draining: %+v", stats)
+		t.FailNow()
+	}
+}
+
+func TestUDP_ListensOnIPv6(t *testing.T) {
+	if conn, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback}); err != nil {
+		t.Skip("IPv6 is not available:", err)
+	} else {
+		conn.Close()
+	}
+
+	received := make(chan string, 1)
+	testHandler := func(addr *net.UDPAddr, data []byte, err error) {
+		received <- addr.IP.String()
+	}
+
+	reg := NewRegistry(Config{})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go reg.HandleUDP("[::1]:9996", testHandler, stopCh)
+	time.Sleep(50 * time.Millisecond) // Wait for the listener to start.
+
+	conn, err := net.DialUDP("udp6", nil, &net.UDPAddr{IP: net.IPv6loopback, Port: 9996})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer conn.Close()
+	conn.Write([]byte("beacon"))
+
+	select {
+	case ip := <-received:
+		if ip != "::1" {
+			t.Log("expected beacon from ::1, got", ip)
+			t.FailNow()
+		}
+	case <-time.After(5 * time.Second):
+		t.Log("beacon was not received on the IPv6 address")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,65 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	beacon "github.com/willroberts/openrvs-beacon"
+)
+
+// VerificationPolicy controls how the registry confirms that a registration was
+// sent by the server it describes, before the server is added to the list.
+// Without verification, a single spoofed datagram can cause the registry to
+// healthcheck an arbitrary host indefinitely.
+type VerificationPolicy string
+
+const (
+	// VerifyNone adds servers whether or not they respond to the initial
+	// healthcheck. This is the default when no policy is configured.
+	VerifyNone VerificationPolicy = "none"
+
+	// VerifyProbe only adds servers which answer a beacon request sent to the
+	// reported beacon port with a report for the same game port.
+	VerifyProbe VerificationPolicy = "probe"
+
+	// VerifySource applies VerifyProbe, and also requires UDP registrations to
+	// be sent from the reported beacon port.
+	VerifySource VerificationPolicy = "source"
+)
+
+// errVerificationFailed is returned when a registration does not satisfy
+// Config.RegistrationVerification.
+var errVerificationFailed = errors.New("registration could not be verified")
+
+// verifyRegistration checks a registration received from source against
+// Config.RegistrationVerification. The data and probeErr parameters are the
+// result of probing the reported beacon port. When the source port is unknown,
+// it is not checked.
+func (r *registry) verifyRegistration(report *beacon.ServerReport, source *net.UDPAddr, data []byte, probeErr error) error {
+	switch r.config().RegistrationVerification {
+	case VerifySource:
+		beaconPort := report.BeaconPort
+		if beaconPort == 0 {
+			beaconPort = report.Port + 1000
+		}
+		if source.Port != 0 && source.Port != beaconPort {
+			return fmt.Errorf("%w: beacon was sent from port %d instead of %d", errVerificationFailed, source.Port, beaconPort)
+		}
+		fallthrough
+
+	case VerifyProbe:
+		if probeErr != nil {
+			return fmt.Errorf("%w: %v", errVerificationFailed, probeErr)
+		}
+		probed, err := beacon.ParseServerReport(report.IPAddress, data)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errVerificationFailed, err)
+		}
+		if probed.Port != report.Port {
+			return fmt.Errorf("%w: server reported port %d instead of %d", errVerificationFailed, probed.Port, report.Port)
+		}
+	}
+
+	return nil
+}
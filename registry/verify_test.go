@@ -0,0 +1,53 @@
+package registry
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAddServer_Verification(t *testing.T) {
+	const mode = "RGM_TerroristHuntCoopMode"
+	beaconPort := startTestBeacon(t, "Verified", mode)
+	port := beaconPort - 1000
+
+	cases := []struct {
+		name     string
+		policy   VerificationPolicy
+		source   *net.UDPAddr
+		report   []byte
+		verified bool
+	}{
+		{"none with no beacon", VerifyNone, nil, newTestReport("Spoofed", 7777, 1, mode), true},
+		{"probe with no beacon", VerifyProbe, nil, newTestReport("Spoofed", 7777, 1, mode), false},
+		{"probe with wrong port", VerifyProbe, nil, newTestReport("Spoofed", port+1, beaconPort, mode), false},
+		{"probe", VerifyProbe, nil, newTestReport("Verified", port, beaconPort, mode), true},
+		{"source with wrong port", VerifySource, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}, newTestReport("Verified", port, beaconPort, mode), false},
+		{"source", VerifySource, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: beaconPort}, newTestReport("Verified", port, beaconPort, mode), true},
+		{"source with unknown port", VerifySource, nil, newTestReport("Verified", port, beaconPort, mode), true},
+	}
+
+	for _, c := range cases {
+		reg := NewRegistry(Config{
+			HealthcheckTimeout:       100 * time.Millisecond,
+			RegistrationVerification: c.policy,
+		}).(*registry)
+
+		var err error
+		if c.source != nil {
+			err = reg.addServer(c.source, c.report)
+		} else {
+			err = reg.AddServer("127.0.0.1", c.report)
+		}
+
+		if c.verified && (err != nil || reg.ServerCount() != 1) {
+			t.Logf("%s: expected server to be added, got error: %v", c.name, err)
+			t.FailNow()
+		}
+		if !c.verified && (!errors.Is(err, errVerificationFailed) || reg.ServerCount() != 0) {
+			t.Logf("%s: expected verification to fail, got error: %v", c.name, err)
+			t.FailNow()
+		}
+	}
+}